@@ -6,17 +6,19 @@ import (
 	"crypto/rand"
 	"encoding/base32"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
-
-	exec "github.com/getsentry/sentry-sdk-benchmark/internal/std/execabs"
 )
 
 var dockerComposeTemplate = template.Must(template.ParseFiles(filepath.Join("template", "docker-compose.yml.tmpl")))
@@ -27,23 +29,339 @@ type BenchmarkConfig struct {
 	Platform       string         // a valid path like platform/python/django
 	PlatformConfig PlatformConfig // from platform/*/*/config.json
 	Runs           []RunConfig
+	Count          int    // number of times to run each app
+	Rebuild        bool   // build images even if cached
+	Keep           bool   // leave the containers of every run running, for debugging
+	StatsSource    string // where loadgen reads container stats, one of StatsSources, empty for cAdvisor
+
+	RunTimeout time.Duration // max time a run may take after building images, zero for no limit
+	Deadline   time.Time     // time after which no run may continue, zero for no limit
+
+	// Overrides holds the platform configuration fields overridden from
+	// the command line, keyed by flag name, e.g. "duration": "1m".
+	Overrides map[string]string
+}
+
+// OverrideFlags lists the names of the command line flags that override
+// fields of the platform configuration.
+var OverrideFlags = []string{"rps", "duration", "warmup", "maxwait", "target"}
+
+// Override overrides a field of the platform configuration, identified by
+// the name of the corresponding command line flag. The resulting configuration
+// is validated by the caller.
+func (cfg *BenchmarkConfig) Override(name, value string) error {
+	pc := &cfg.PlatformConfig
+	switch name {
+	case "rps":
+		rates, err := ParseRates(value)
+		if err != nil {
+			return err
+		}
+		pc.RPS = rates
+	case "duration":
+		pc.Duration = value
+	case "warmup":
+		pc.Warmup = value
+	case "maxwait":
+		pc.MaxWait = value
+	case "target":
+		pc.Target.Path = value
+		pc.Targets = nil
+	default:
+		return fmt.Errorf("unknown override: %q", name)
+	}
+	if cfg.Overrides == nil {
+		cfg.Overrides = make(map[string]string)
+	}
+	cfg.Overrides[name] = value
+	return nil
 }
 
 type PlatformConfig struct {
 	Target struct {
 		Path string
 	}
-	RPS      uint16
+	Targets  []TargetConfig `json:",omitempty"` // optional, endpoints requested in proportion to their weights
+	RPS      Rates          // one or more request rates; more than one rate runs a sweep
 	Duration string
-	MaxWait  string // optional, use for platforms that are notably slow to boot
+	Warmup   string          // optional, defaults to the loadgen default
+	MaxWait  string          // optional, use for platforms that are notably slow to boot
+	Capacity *CapacityConfig `json:",omitempty"` // optional, search for max sustainable throughput
+	Profile  *LoadProfile    `json:",omitempty"` // optional, vary the request rate during the test
+
+	// Variants are additional runs that reuse an app directory with a
+	// different configuration, e.g. to compare SDK options without copying
+	// the app.
+	Variants []VariantConfig `json:",omitempty"`
+
+	// SDKVersions optionally runs an app once per SDK version, e.g. to find
+	// regressions between releases.
+	SDKVersions *SDKVersionsConfig `json:",omitempty"`
+
+	// Model selects how load is generated, either "open" (default), at a
+	// fixed request rate, or "closed", with a fixed number of virtual users
+	// issuing requests back-to-back.
+	Model       string `json:",omitempty"`
+	Concurrency uint16 `json:",omitempty"` // closed model: number of virtual users
+	ThinkTime   string `json:",omitempty"` // closed model, optional: pause between requests
+}
+
+// VariantConfig configures a named run of an app with additional environment
+// variables or Docker build arguments.
+type VariantConfig struct {
+	Name      string            // unique name of the run, e.g. "instrumented@0.1"
+	App       string            // app directory, e.g. "instrumented"
+	Env       map[string]string `json:",omitempty"` // environment variables of the app container
+	BuildArgs map[string]string `json:",omitempty"` // build arguments of the app image
+}
+
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// relayEnv lists the environment variables that docker-compose.yml.tmpl sets
+// for apps that report to the relay. Variants must not set them, as the
+// environment would have duplicate keys.
+var relayEnv = []string{"SENTRY_DSN", "OTEL_EXPORTER_ZIPKIN_ENDPOINT"}
+
+func (v VariantConfig) validate() error {
+	if v.Name == "" || strings.ContainsAny(v.Name, `/\ `) {
+		return fmt.Errorf(`platform config invalid "variants.name": %q`, v.Name)
+	}
+	// Result directories named like a repetition or a rate hold the runs
+	// of a repetition or rate, see findRunResults.
+	_, errRep := strconv.Atoi(v.Name)
+	if _, isRate := parseRPSDir(v.Name); errRep == nil || isRate {
+		return fmt.Errorf(`platform config invalid "variants.name": %q: reserved for repetitions and rates`, v.Name)
+	}
+	if v.App == "" || strings.ContainsAny(v.App, `/\`) {
+		return fmt.Errorf(`platform config invalid "variants.app" for %q: %q`, v.Name, v.App)
+	}
+	for k := range v.Env {
+		if !envNameRegex.MatchString(k) {
+			return fmt.Errorf(`platform config invalid "variants.env" for %q: %q`, v.Name, k)
+		}
+		if contains(relayEnv, k) {
+			return fmt.Errorf(`platform config invalid "variants.env" for %q: %q: set by the runner`, v.Name, k)
+		}
+	}
+	for k := range v.BuildArgs {
+		if !envNameRegex.MatchString(k) {
+			return fmt.Errorf(`platform config invalid "variants.buildargs" for %q: %q`, v.Name, k)
+		}
+	}
+	return nil
+}
+
+// SDKVersionsConfig configures a matrix of SDK versions. The runner passes each
+// version to the Docker build of the app as a build argument, which the app's
+// Dockerfile must declare, e.g. "ARG SENTRY_SDK_VERSION". The versioned runs,
+// named like "instrumented@1.5.0", replace the run of the app itself.
+type SDKVersionsConfig struct {
+	App      string `json:",omitempty"` // optional, defaults to "instrumented"
+	BuildArg string `json:",omitempty"` // optional, defaults to "SENTRY_SDK_VERSION"
+	Versions []string
+}
+
+func (c SDKVersionsConfig) app() string {
+	if c.App == "" {
+		return "instrumented"
+	}
+	return c.App
+}
+
+func (c SDKVersionsConfig) buildArg() string {
+	if c.BuildArg == "" {
+		return "SENTRY_SDK_VERSION"
+	}
+	return c.BuildArg
+}
+
+// runs returns one run of the app per SDK version.
+func (c SDKVersionsConfig) runs() []RunConfig {
+	var runs []RunConfig
+	for _, v := range c.Versions {
+		runs = append(runs, RunConfig{
+			Name:       c.app() + "@" + v,
+			App:        c.app(),
+			NeedsRelay: c.app() != "baseline",
+			BuildArgs:  map[string]string{c.buildArg(): v},
+		})
+	}
+	return runs
+}
+
+func (c SDKVersionsConfig) validate() error {
+	if len(c.Versions) == 0 {
+		return fmt.Errorf(`platform config missing "sdkversions.versions"`)
+	}
+	if strings.ContainsAny(c.App, `/\`) {
+		return fmt.Errorf(`platform config invalid "sdkversions.app": %q`, c.App)
+	}
+	if !envNameRegex.MatchString(c.buildArg()) {
+		return fmt.Errorf(`platform config invalid "sdkversions.buildarg": %q`, c.BuildArg)
+	}
+	seen := make(map[string]bool)
+	for _, v := range c.Versions {
+		if v == "" || strings.ContainsAny(v, `/\ `) || seen[v] {
+			return fmt.Errorf(`platform config invalid "sdkversions.versions": %q`, v)
+		}
+		seen[v] = true
+	}
+	return nil
+}
+
+// TargetConfig is an endpoint of an app, like "/fortunes".
+type TargetConfig struct {
+	Method string `json:",omitempty"` // optional, defaults to GET
+	Path   string
+	Weight uint16
+}
+
+// TargetPath returns the path of the target used to check that an app is
+// ready to receive traffic.
+func (cfg PlatformConfig) TargetPath() string {
+	if cfg.Target.Path == "" && len(cfg.Targets) > 0 {
+		return cfg.Targets[0].Path
+	}
+	return cfg.Target.Path
+}
+
+// IsClosedModel reports whether load is generated with the closed model.
+func (cfg PlatformConfig) IsClosedModel() bool {
+	return cfg.Model == "closed"
+}
+
+// CapacityConfig configures the search for the maximum sustainable throughput
+// of an app. The search finds the highest request rate at which the app still
+// meets the configured service level objective.
+type CapacityConfig struct {
+	Success float64 // minimum ratio of successful requests, e.g. 0.99
+	P99     string  // maximum 99th percentile latency, e.g. "100ms"
+	Step    string  `json:",omitempty"` // optional, duration of each search step
+	Max     uint16  `json:",omitempty"` // optional, maximum request rate
+}
+
+// LoadProfile varies the request rate during the test, starting from the base
+// rate given by "rps". The warm up always runs at the base rate.
+type LoadProfile struct {
+	Type   string // "ramp", "steps", "spike" or "sine"
+	To     uint16 `json:",omitempty"` // ramp: rate at the end of the test
+	Step   uint16 `json:",omitempty"` // steps: rate added at every step
+	Peak   uint16 `json:",omitempty"` // spike: rate during a spike
+	Amp    uint16 `json:",omitempty"` // sine: amplitude of the rate
+	Every  string `json:",omitempty"` // steps, spike: interval between steps or spikes
+	Length string `json:",omitempty"` // spike: duration of a spike
+	Period string `json:",omitempty"` // sine: period of the wave
+}
+
+// String returns the profile in the format of the loadgen -profile flag.
+func (p LoadProfile) String() string {
+	switch p.Type {
+	case "ramp":
+		return fmt.Sprintf("ramp:to=%d", p.To)
+	case "steps":
+		return fmt.Sprintf("steps:step=%d,every=%s", p.Step, p.Every)
+	case "spike":
+		return fmt.Sprintf("spike:peak=%d,every=%s,length=%s", p.Peak, p.Every, p.Length)
+	case "sine":
+		return fmt.Sprintf("sine:amp=%d,period=%s", p.Amp, p.Period)
+	}
+	return p.Type
+}
+
+func (p LoadProfile) validate(rates Rates) error {
+	positive := func(field, s string) error {
+		if d, err := time.ParseDuration(s); err != nil || d <= 0 {
+			return fmt.Errorf(`platform config invalid "profile.%s": %q`, field, s)
+		}
+		return nil
+	}
+	switch p.Type {
+	case "ramp":
+		if p.To == 0 {
+			return fmt.Errorf(`platform config missing "profile.to"`)
+		}
+		for _, rps := range rates {
+			if p.To == rps {
+				return fmt.Errorf(`platform config invalid "profile.to": %d: equal to rate %d`, p.To, rps)
+			}
+		}
+		return nil
+	case "steps":
+		if p.Step == 0 {
+			return fmt.Errorf(`platform config missing "profile.step"`)
+		}
+		return positive("every", p.Every)
+	case "spike":
+		for _, rps := range rates {
+			if p.Peak <= rps {
+				return fmt.Errorf(`platform config invalid "profile.peak": %d: not larger than rate %d`, p.Peak, rps)
+			}
+		}
+		if err := positive("every", p.Every); err != nil {
+			return err
+		}
+		if err := positive("length", p.Length); err != nil {
+			return err
+		}
+		every, _ := time.ParseDuration(p.Every)
+		length, _ := time.ParseDuration(p.Length)
+		if length > every {
+			return fmt.Errorf(`platform config invalid "profile.length": %q: longer than "profile.every"`, p.Length)
+		}
+		return nil
+	case "sine":
+		for _, rps := range rates {
+			if p.Amp > rps {
+				return fmt.Errorf(`platform config invalid "profile.amp": %d: larger than rate %d`, p.Amp, rps)
+			}
+		}
+		return positive("period", p.Period)
+	}
+	return fmt.Errorf(`platform config invalid "profile.type": %q: must be "ramp", "steps", "spike" or "sine"`, p.Type)
 }
 
 func (cfg PlatformConfig) Validate() error {
-	if cfg.Target.Path == "" {
+	if cfg.Target.Path == "" && len(cfg.Targets) == 0 {
 		return fmt.Errorf(`platform config missing "target.path"`)
 	}
-	if cfg.RPS == 0 {
-		return fmt.Errorf(`platform config missing "rps"`)
+	for _, t := range cfg.Targets {
+		if !strings.HasPrefix(t.Path, "/") {
+			return fmt.Errorf(`platform config invalid "targets.path": %q: must start with "/"`, t.Path)
+		}
+		if t.Weight == 0 {
+			return fmt.Errorf(`platform config missing "targets.weight" for %q`, t.Path)
+		}
+	}
+	switch cfg.Model {
+	case "", "open":
+		if len(cfg.RPS) == 0 {
+			return fmt.Errorf(`platform config missing "rps"`)
+		}
+	case "closed":
+		if cfg.Concurrency == 0 {
+			return fmt.Errorf(`platform config missing "concurrency" for closed model`)
+		}
+		if len(cfg.RPS) > 1 {
+			return fmt.Errorf(`platform config invalid "rps": %v: sweeps require the open model`, cfg.RPS)
+		}
+		if cfg.ThinkTime != "" {
+			if d, err := time.ParseDuration(cfg.ThinkTime); err != nil || d < 0 {
+				return fmt.Errorf(`platform config invalid "thinktime": %q`, cfg.ThinkTime)
+			}
+		}
+		if cfg.Profile != nil {
+			return fmt.Errorf(`platform config invalid "profile": load profiles require the open model`)
+		}
+		if cfg.Capacity != nil {
+			return fmt.Errorf(`platform config invalid "capacity": capacity search requires the open model`)
+		}
+	default:
+		return fmt.Errorf(`platform config invalid "model": %q: must be "open" or "closed"`, cfg.Model)
+	}
+	for _, rps := range cfg.RPS {
+		if rps == 0 {
+			return fmt.Errorf(`platform config invalid "rps": %v: rates must be positive`, cfg.RPS)
+		}
 	}
 	d, err := time.ParseDuration(cfg.Duration)
 	if err != nil {
@@ -52,14 +370,162 @@ func (cfg PlatformConfig) Validate() error {
 	if d <= 0 {
 		return fmt.Errorf(`platform config nonpositive "duration": %q`, cfg.Duration)
 	}
+	if cfg.Warmup != "" {
+		if d, err := time.ParseDuration(cfg.Warmup); err != nil || d < 0 {
+			return fmt.Errorf(`platform config invalid "warmup": %q`, cfg.Warmup)
+		}
+	}
 	if cfg.MaxWait != "" {
 		if _, err := time.ParseDuration(cfg.MaxWait); err != nil {
 			return fmt.Errorf(`platform config invalid "maxwait": %q: %s`, cfg.MaxWait, err)
 		}
 	}
+	if c := cfg.Capacity; c != nil {
+		if c.Success <= 0 || c.Success > 1 {
+			return fmt.Errorf(`platform config invalid "capacity.success": %v: must be in (0, 1]`, c.Success)
+		}
+		if d, err := time.ParseDuration(c.P99); err != nil || d <= 0 {
+			return fmt.Errorf(`platform config invalid "capacity.p99": %q`, c.P99)
+		}
+		if c.Step != "" {
+			if d, err := time.ParseDuration(c.Step); err != nil || d <= 0 {
+				return fmt.Errorf(`platform config invalid "capacity.step": %q`, c.Step)
+			}
+		}
+	}
+	if p := cfg.Profile; p != nil {
+		if err := p.validate(cfg.RPS); err != nil {
+			return err
+		}
+	}
+	if c := cfg.SDKVersions; c != nil {
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+	// Runs are named in compose projects and containers by composeName, so
+	// names must be unique in that form.
+	names := make(map[string]bool)
+	if c := cfg.SDKVersions; c != nil {
+		for _, r := range c.runs() {
+			names[composeName(r.Name)] = true
+		}
+	}
+	for _, v := range cfg.Variants {
+		if err := v.validate(); err != nil {
+			return err
+		}
+		if names[composeName(v.Name)] {
+			return fmt.Errorf(`platform config duplicate "variants.name": %q`, v.Name)
+		}
+		names[composeName(v.Name)] = true
+		if c := cfg.SDKVersions; c != nil && c.app() == v.App {
+			if _, ok := v.BuildArgs[c.buildArg()]; ok {
+				return fmt.Errorf(`platform config invalid "variants.buildargs" for %q: %q: set by "sdkversions"`, v.Name, c.buildArg())
+			}
+		}
+	}
+	return nil
+}
+
+// Rates is a list of request rates in requests per second.
+//
+// In JSON, Rates is either a single number, an array of numbers or a string in
+// the format accepted by ParseRates.
+type Rates []uint16
+
+// ParseRates parses a comma-separated list of rates, where each element is
+// either a single rate like "10" or a range like "10-100/10" (from 10 to 100,
+// inclusive, in steps of 10).
+func ParseRates(s string) (Rates, error) {
+	var rates Rates
+	for _, elem := range strings.Split(s, ",") {
+		elem = strings.TrimSpace(elem)
+		i := strings.Index(elem, "-")
+		if i < 0 {
+			rps, err := strconv.ParseUint(elem, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rate: %q", elem)
+			}
+			rates = append(rates, uint16(rps))
+			continue
+		}
+		j := strings.Index(elem, "/")
+		if j < i {
+			return nil, fmt.Errorf("invalid range: %q: missing step", elem)
+		}
+		from, err1 := strconv.ParseUint(elem[:i], 10, 16)
+		to, err2 := strconv.ParseUint(elem[i+1:j], 10, 16)
+		step, err3 := strconv.ParseUint(elem[j+1:], 10, 16)
+		if err1 != nil || err2 != nil || err3 != nil || step == 0 || from > to {
+			return nil, fmt.Errorf("invalid range: %q", elem)
+		}
+		for rps := from; rps <= to; rps += step {
+			rates = append(rates, uint16(rps))
+		}
+	}
+	return rates, nil
+}
+
+func (r Rates) String() string {
+	s := make([]string, len(r))
+	for i, rps := range r {
+		s[i] = strconv.Itoa(int(rps))
+	}
+	return strings.Join(s, ",")
+}
+
+// Set implements flag.Value.
+func (r *Rates) Set(s string) error {
+	rates, err := ParseRates(s)
+	if err != nil {
+		return err
+	}
+	*r = rates
 	return nil
 }
 
+func (r *Rates) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		rps, err := jsonRate(v)
+		if err != nil {
+			return err
+		}
+		*r = Rates{rps}
+	case string:
+		return r.Set(v)
+	default:
+		var values []float64
+		if err := json.Unmarshal(b, &values); err != nil {
+			return fmt.Errorf("invalid rates: %s", b)
+		}
+		rates := make(Rates, len(values))
+		for i, v := range values {
+			rps, err := jsonRate(v)
+			if err != nil {
+				return err
+			}
+			rates[i] = rps
+		}
+		*r = rates
+	}
+	return nil
+}
+
+// jsonRate converts a JSON number to a rate, rejecting numbers that are not
+// integers or do not fit, like ParseRates.
+func jsonRate(v float64) (uint16, error) {
+	if v < 0 || v > math.MaxUint16 || v != math.Trunc(v) {
+		return 0, fmt.Errorf("invalid rate: %q", strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return uint16(v), nil
+}
+
 // BenchmarkConfigFromPath returns the necessary configuration to run a
 // benchmark targeting the app or apps at the given path.
 //
@@ -67,22 +533,30 @@ func (cfg PlatformConfig) Validate() error {
 // directories, or path must be an app directory whose parent contains a
 // configuration file.
 //
-// BenchmarkConfigFromPath panics if it cannot create a valid configuration from
-// the given path.
+// BenchmarkConfigFromPath returns an error if it cannot create a valid
+// configuration from the given path.
 //
 // Path is always cleaned with filepath.Clean, such that equivalent spellings of
 // the same path will return equivalent configuration.
-func BenchmarkConfigFromPath(path string) BenchmarkConfig {
+func BenchmarkConfigFromPath(path string) (BenchmarkConfig, error) {
 	path = filepath.Clean(path)
 	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
-		panic(fmt.Errorf("could not read directory: %q", path))
+		return BenchmarkConfig{}, fmt.Errorf("could not read directory: %q", path)
+	}
+	pcpath, err := FindPlatformConfig(path)
+	if err != nil {
+		return BenchmarkConfig{}, err
+	}
+	pc, err := ReadPlatformConfig(pcpath)
+	if err != nil {
+		return BenchmarkConfig{}, err
 	}
-	pcpath := MustFindPlatformConfig(path)
 	cfg := BenchmarkConfig{
 		ID:             NewBenchmarkID(),
 		StartTime:      time.Now().UTC(),
 		Platform:       filepath.Dir(pcpath),
-		PlatformConfig: MustReadPlatformConfig(pcpath),
+		PlatformConfig: pc,
+		Count:          1,
 	}
 	var apps []string
 	if cfg.Platform == path {
@@ -93,49 +567,102 @@ func BenchmarkConfigFromPath(path string) BenchmarkConfig {
 		apps = []string{path}
 	}
 	if len(apps) == 0 {
-		panic(fmt.Errorf("no app to benchmark in %q", path))
+		return BenchmarkConfig{}, fmt.Errorf("no app to benchmark in %q", path)
 	}
 	for _, app := range apps {
 		name := filepath.Base(app)
+		if c := cfg.PlatformConfig.SDKVersions; c != nil && c.app() == name {
+			cfg.Runs = append(cfg.Runs, c.runs()...)
+			continue
+		}
 		cfg.Runs = append(cfg.Runs, RunConfig{
 			Name:       name,
+			App:        name,
 			NeedsRelay: name != "baseline",
 		})
 	}
-	return cfg
+	if c := cfg.PlatformConfig.SDKVersions; c != nil && cfg.Platform == path {
+		if _, err := os.Stat(filepath.Join(path, c.app())); err != nil {
+			return BenchmarkConfig{}, fmt.Errorf("sdk versions: no app %q in %q", c.app(), path)
+		}
+	}
+	// Variants run after the apps they are based on.
+	for _, v := range cfg.PlatformConfig.Variants {
+		selected := false
+		for _, app := range apps {
+			if filepath.Base(app) == v.App {
+				selected = true
+			}
+			if composeName(filepath.Base(app)) == composeName(v.Name) {
+				return BenchmarkConfig{}, fmt.Errorf("variant %q has the same name as an app", v.Name)
+			}
+		}
+		if !selected {
+			if cfg.Platform == path {
+				return BenchmarkConfig{}, fmt.Errorf("variant %q: no app %q in %q", v.Name, v.App, path)
+			}
+			continue
+		}
+		cfg.Runs = append(cfg.Runs, RunConfig{
+			Name:       v.Name,
+			App:        v.App,
+			NeedsRelay: v.App != "baseline",
+			Env:        v.Env,
+			BuildArgs:  v.BuildArgs,
+		})
+	}
+	return cfg, nil
 }
 
-// MustFindPlatformConfig returns the path to the platform configuration for the
+// FindPlatformConfig returns the path to the platform configuration for the
 // given path. Path itself must contain a configuration file or path's parent
-// directory must contain a configuration file. MustFindPlatformConfig panics if
-// a configuration file cannot be found.
-func MustFindPlatformConfig(path string) string {
+// directory must contain a configuration file.
+func FindPlatformConfig(path string) (string, error) {
 	candidates := []string{
 		filepath.Join(path, "config.json"),
 		filepath.Join(filepath.Dir(path), "config.json"),
 	}
 	for _, p := range candidates {
 		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
-			return p
+			return p, nil
 		}
 	}
-	panic(fmt.Errorf("no config file found in: %q", candidates))
+	return "", fmt.Errorf("no config file found in: %q", candidates)
 }
 
-// MustReadPlatformConfig reads and validates a PlatformConfig from path. It
-// panics if the configuration cannot be read or is invalid.
-func MustReadPlatformConfig(path string) PlatformConfig {
-	f, err := os.Open(path)
+// MustFindPlatformConfig is like FindPlatformConfig but panics if a
+// configuration file cannot be found.
+func MustFindPlatformConfig(path string) string {
+	p, err := FindPlatformConfig(path)
 	if err != nil {
 		panic(err)
 	}
+	return p
+}
+
+// ReadPlatformConfig reads and validates a PlatformConfig from path.
+func ReadPlatformConfig(path string) (PlatformConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return PlatformConfig{}, err
+	}
 	defer f.Close()
 	var pc PlatformConfig
 	err = json.NewDecoder(f).Decode(&pc)
 	if err != nil {
-		panic(err)
+		return PlatformConfig{}, fmt.Errorf("%s: %w", path, err)
 	}
 	if err := pc.Validate(); err != nil {
+		return PlatformConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return pc, nil
+}
+
+// MustReadPlatformConfig is like ReadPlatformConfig but panics if the
+// configuration cannot be read or is invalid.
+func MustReadPlatformConfig(path string) PlatformConfig {
+	pc, err := ReadPlatformConfig(path)
+	if err != nil {
 		panic(err)
 	}
 	return pc
@@ -159,19 +686,150 @@ func subDirs(path string) []string {
 
 type RunConfig struct {
 	Name       string
+	App        string // app directory, equal to Name unless the run is a variant
 	NeedsRelay bool
+	Env        map[string]string // additional environment variables of the app
+	BuildArgs  map[string]string // build arguments of the app image
+	Repetition int               // 1-based repetition number when Count > 1, zero otherwise
+	RPS        uint16            // request rate, set by Schedule
+}
+
+// Schedule returns all runs of the benchmark in execution order.
+//
+// Every app runs once per configured request rate. When Count is greater than
+// one, this is repeated Count times and the order of the apps is rotated on
+// every repetition (e.g. B-I-O, O-B-I, I-O-B), such that host drift, thermal
+// throttling and page cache effects do not always affect the same app.
+func (cfg BenchmarkConfig) Schedule() []RunConfig {
+	count := cfg.Count
+	if count < 1 {
+		count = 1
+	}
+	rates := cfg.PlatformConfig.RPS
+	if len(rates) == 0 {
+		// closed model
+		rates = Rates{0}
+	}
+	n := len(cfg.Runs)
+	s := make([]RunConfig, 0, count*len(rates)*n)
+	for i := 0; i < count; i++ {
+		for _, rps := range rates {
+			for j := 0; j < n; j++ {
+				r := cfg.Runs[((j-i)%n+n)%n]
+				if count > 1 {
+					r.Repetition = i + 1
+				}
+				r.RPS = rps
+				s = append(s, r)
+			}
+		}
+	}
+	return s
+}
+
+// IsSweep reports whether apps run at more than one request rate.
+func (cfg PlatformConfig) IsSweep() bool {
+	return len(cfg.RPS) > 1
 }
 
 type DockerComposeData struct {
-	ID              BenchmarkID
-	RunName         string
-	PlatformConfig  PlatformConfig
-	App             App
-	ResultPath      string
-	NeedsRelay      bool
-	Language        string
-	Framework       string
-	SanityCheckMode bool
+	ID             BenchmarkID
+	RunName        string // name of the run, sanitized for use in container names
+	PlatformConfig PlatformConfig
+	RPS            uint16
+	App            App
+	ResultPath     string
+	NeedsRelay     bool
+	Env            map[string]string
+	BuildArgs      map[string]string
+	Language       string
+	Framework      string
+	Images         Images
+	Keep           bool   // publish ports to the host
+	StatsSource    string // one of StatsSources, empty for cAdvisor
+}
+
+// StatsSources lists where loadgen can read container stats from: a cAdvisor
+// container, the Docker Engine API or the cgroup v2 files of the host.
+var StatsSources = []string{"cadvisor", "docker", "cgroup"}
+
+// usesCAdvisor reports whether the stats source requires a cAdvisor container.
+func usesCAdvisor(statsSource string) bool {
+	return statsSource == "" || statsSource == "cadvisor"
+}
+
+// UsesCAdvisor reports whether the run has a cAdvisor container.
+func (d DockerComposeData) UsesCAdvisor() bool {
+	return usesCAdvisor(d.StatsSource)
+}
+
+// ContainerName returns the name of a container of the run, like
+// "app-baseline-tbnfsga".
+func (d DockerComposeData) ContainerName(name string) string {
+	return fmt.Sprintf("%s-%s-%s", name, d.RunName, d.ID)
+}
+
+// LoadGenArgs returns the command line arguments of the load generator.
+func (d DockerComposeData) LoadGenArgs() []string {
+	pc := d.PlatformConfig
+	args := []string{"-target", "http://app:8080" + pc.TargetPath()}
+	if len(pc.Targets) > 0 {
+		args = append(args, "-targets", "/result/"+d.ResultPath+"/targets.json")
+	}
+	if pc.IsClosedModel() {
+		args = append(args, "-concurrency", strconv.Itoa(int(pc.Concurrency)))
+		if pc.ThinkTime != "" {
+			args = append(args, "-think", pc.ThinkTime)
+		}
+	} else {
+		args = append(args, "-rps", strconv.Itoa(int(d.RPS)))
+		if pc.Profile != nil {
+			args = append(args, "-profile", pc.Profile.String())
+		}
+	}
+	args = append(args, "-test", pc.Duration)
+	if pc.MaxWait != "" {
+		args = append(args, "-maxwait", pc.MaxWait)
+	}
+	if c := pc.Capacity; c != nil {
+		args = append(args,
+			"-capacity",
+			"-slo-success", fmt.Sprint(c.Success),
+			"-slo-p99", c.P99,
+		)
+		if c.Step != "" {
+			args = append(args, "-capacity-step", c.Step)
+		}
+		if c.Max != 0 {
+			args = append(args, "-capacity-max", strconv.Itoa(int(c.Max)))
+		}
+	}
+	if pc.Warmup != "" {
+		args = append(args, "-warmup", pc.Warmup)
+	}
+	containers := []string{"app", "postgres", "loadgen"}
+	switch d.StatsSource {
+	case "", "cadvisor":
+		args = append(args, "-cadvisor", "http://cadvisor:8080")
+		containers = append(containers, "cadvisor")
+	case "cgroup":
+		// See the volumes of loadgen in the compose template.
+		args = append(args, "-stats", d.StatsSource, "-cgroup", "/host/sys/fs/cgroup", "-proc", "/host/proc")
+	default:
+		args = append(args, "-stats", d.StatsSource)
+	}
+	if d.NeedsRelay {
+		args = append(args, "-fakerelay", "http://relay:5000")
+		containers = append(containers, "fakerelay")
+	}
+	for i, c := range containers {
+		containers[i] = d.ContainerName(c)
+	}
+	args = append(args,
+		"-containers", strings.Join(containers, ","),
+		"-out", "/result/"+d.ResultPath,
+	)
+	return args
 }
 
 type App struct {
@@ -198,147 +856,463 @@ func (r BenchmarkID) String() string {
 	return base32Encoding.EncodeToString(r[:])
 }
 
-func Benchmark(ctx context.Context, cfg BenchmarkConfig) {
+// Benchmark runs all apps of a benchmark with orchestrator o and writes a
+// report of the runs that succeeded. A failed run does not stop the benchmark:
+// its error is stored in the returned results and its status in status.json in
+// its result directory. Benchmark returns an error if no report could be
+// written.
+func Benchmark(ctx context.Context, o Orchestrator, cfg BenchmarkConfig) ([]*RunResult, error) {
 	oldprefix := log.Prefix()
 	defer log.SetPrefix(oldprefix)
 	log.SetPrefix(fmt.Sprintf("%s[%s] ", oldprefix, cfg.ID))
 
-	var results []*RunResult
-	for _, runCfg := range cfg.Runs {
-		results = append(results, run(ctx, cfg, runCfg))
+	if len(cfg.Overrides) > 0 {
+		if err := os.MkdirAll(cfg.ResultPath(), 0777); err != nil {
+			return nil, err
+		}
+		b, err := json.MarshalIndent(cfg.Overrides, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(cfg.ResultPath(), "overrides.json"), b, 0666); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writeEnvironment(CollectEnvironment(ctx, o), cfg.ResultPath()); err != nil {
+		return nil, err
 	}
 
-	report(results)
+	schedule := cfg.Schedule()
+	if cfg.Keep && len(schedule) > 1 {
+		log.Printf("Keeping the containers of all %d runs, they may affect each other's results", len(schedule))
+	}
+	var results, reported []*RunResult
+	// skipped is the error of the runs that are skipped after the benchmark
+	// was interrupted or the session timeout was reached.
+	var skipped error
+	for _, runCfg := range schedule {
+		if skipped == nil {
+			if ctx.Err() != nil {
+				log.Print("Interrupted, skipping remaining runs")
+				skipped = runError(StatusCanceled, ctx.Err())
+			} else if !cfg.Deadline.IsZero() && !time.Now().Before(cfg.Deadline) {
+				log.Print("Session timeout reached, skipping remaining runs")
+				skipped = runError(StatusTimedOut, errors.New("session timeout exceeded before starting"))
+			}
+		}
+		if skipped != nil {
+			// Record skipped runs, such that they show up in the
+			// summary and their result directory tells why they
+			// have no result.
+			plan, _ := planRun(cfg, runCfg)
+			res := plan.Result
+			res.Err = skipped
+			if err := writeStatus(res, 0); err != nil {
+				log.Printf("Could not write status of %s: %s", res.Label(), err)
+			}
+			results = append(results, res)
+			continue
+		}
+		start := time.Now()
+		res, err := run(ctx, o, cfg, runCfg)
+		res.Err = err
+		if err != nil {
+			log.Printf("Run %s failed: %s", res.Label(), err)
+		}
+		// Runs that timed out are reported with the partial results
+		// saved by loadgen, if any.
+		if err == nil || (statusOf(err) == StatusTimedOut && res.hasResult()) {
+			reported = append(reported, res)
+		}
+		if err := writeStatus(res, time.Since(start)); err != nil {
+			log.Printf("Could not write status of %s: %s", res.Label(), err)
+		}
+		results = append(results, res)
+	}
+
+	if len(reported) == 0 {
+		return results, errors.New("no successful runs, no report written")
+	}
+	if err := report(cfg.ResultPath(), reported); err != nil {
+		return results, fmt.Errorf("report: %w", err)
+	}
+	return results, nil
+}
+
+// ResultPath returns the directory where results of the benchmark are stored.
+func (cfg BenchmarkConfig) ResultPath() string {
+	return filepath.Join(append(
+		[]string{"result"},
+		append(
+			strings.Split(cfg.Platform, string(os.PathSeparator))[1:],
+			fmt.Sprintf("%s-%s", cfg.StartTime.Format("20060102-150405"), cfg.ID),
+		)...,
+	)...)
 }
 
 type RunResult struct {
 	Name        string
+	App         string // app directory the run is based on
+	Repetition  int
+	RPS         uint16 // request rate, only set for sweeps
 	ComposeFile []byte
 	Path        string
+	Err         error  // error that caused the run to fail, nil on success
+	Project     string // compose project left running, empty unless kept
 }
 
-func run(ctx context.Context, benchmarkCfg BenchmarkConfig, runCfg RunConfig) *RunResult {
-	oldprefix := log.Prefix()
-	defer log.SetPrefix(oldprefix)
-	log.SetPrefix(fmt.Sprintf("%s[%s] ", oldprefix, path.Join(append(strings.Split(benchmarkCfg.Platform, string(os.PathSeparator))[1:], runCfg.Name)...)))
+// Group returns the name under which all repetitions of a run are grouped.
+func (r *RunResult) Group() string {
+	if r.RPS == 0 {
+		return r.Name
+	}
+	return fmt.Sprintf("%s@%drps", r.Name, r.RPS)
+}
 
-	log.Print("START")
-	defer log.Print("END")
+// Label returns a name that uniquely identifies the run within a benchmark.
+func (r *RunResult) Label() string {
+	if r.Repetition == 0 {
+		return r.Group()
+	}
+	return fmt.Sprintf("%s #%d", r.Group(), r.Repetition)
+}
 
+// runPlan describes how an app is run.
+type runPlan struct {
+	Result      *RunResult
+	Project     Project
+	Dockerfile  string // path to the Dockerfile of the app
+	Images      Images
+	LoadGenArgs []string
+}
+
+// planRun computes how to run an app, without side effects. It returns a plan
+// with a result even if planning fails, such that the failure can be recorded
+// in the result directory.
+func planRun(benchmarkCfg BenchmarkConfig, runCfg RunConfig) (runPlan, error) {
 	language := filepath.Base(filepath.Dir(benchmarkCfg.Platform))
 	framework := filepath.Base(benchmarkCfg.Platform)
 
-	projectName := fmt.Sprintf("%s-%s-%s-%s", language, framework, runCfg.Name, benchmarkCfg.ID)
-	contextPath := path.Join(benchmarkCfg.Platform, runCfg.Name)
+	projectName := fmt.Sprintf("%s-%s-%s-%s", language, framework, composeName(runCfg.Name), benchmarkCfg.ID)
+	contextPath := path.Join(benchmarkCfg.Platform, runCfg.App)
 	resultPath := path.Join(append(
 		strings.Split(benchmarkCfg.Platform, string(os.PathSeparator))[1:],
 		fmt.Sprintf("%s-%s", benchmarkCfg.StartTime.Format("20060102-150405"), benchmarkCfg.ID),
-		runCfg.Name,
 	)...)
-	dockerfile := findDockerfile(contextPath)
+	if runCfg.Repetition > 0 {
+		// one subdirectory per repetition
+		resultPath = path.Join(resultPath, strconv.Itoa(runCfg.Repetition))
+	}
+	if benchmarkCfg.PlatformConfig.IsSweep() {
+		// one subdirectory per request rate
+		resultPath = path.Join(resultPath, fmt.Sprintf("%drps", runCfg.RPS))
+	}
+	resultPath = path.Join(resultPath, runCfg.Name)
 
-	var b bytes.Buffer
-	err := dockerComposeTemplate.Execute(&b, DockerComposeData{
+	plan := runPlan{
+		Result: &RunResult{
+			Name:       runCfg.Name,
+			App:        runCfg.App,
+			Repetition: runCfg.Repetition,
+			Path:       filepath.Join("result", filepath.Join(strings.Split(resultPath, "/")...)),
+		},
+	}
+	if benchmarkCfg.PlatformConfig.IsSweep() {
+		plan.Result.RPS = runCfg.RPS
+	}
+
+	dockerfile, err := findDockerfile(contextPath)
+	if err != nil {
+		return plan, err
+	}
+	plan.Dockerfile = path.Join(contextPath, dockerfile)
+
+	images, err := planImages(language, framework, runCfg.App, contextPath, dockerfile, runCfg.BuildArgs)
+	if err != nil {
+		return plan, err
+	}
+	if !usesCAdvisor(benchmarkCfg.StatsSource) {
+		images.CAdvisor = ""
+	}
+	plan.Images = images
+
+	data := DockerComposeData{
 		ID:             benchmarkCfg.ID,
-		RunName:        runCfg.Name,
+		RunName:        composeName(runCfg.Name),
 		PlatformConfig: benchmarkCfg.PlatformConfig,
+		RPS:            runCfg.RPS,
 		App: App{
 			ContextPath: contextPath,
 			Dockerfile:  dockerfile,
 		},
-		ResultPath:      resultPath,
-		NeedsRelay:      runCfg.NeedsRelay,
-		Language:        language,
-		Framework:       framework,
-		SanityCheckMode: sanityCheckMode,
-	})
-	if err != nil {
-		panic(err)
+		ResultPath:  resultPath,
+		NeedsRelay:  runCfg.NeedsRelay,
+		Env:         runCfg.Env,
+		BuildArgs:   runCfg.BuildArgs,
+		Language:    language,
+		Framework:   framework,
+		Images:      images,
+		Keep:        benchmarkCfg.Keep,
+		StatsSource: benchmarkCfg.StatsSource,
+	}
+	var b bytes.Buffer
+	if err := dockerComposeTemplate.Execute(&b, data); err != nil {
+		return plan, err
+	}
+	plan.Result.ComposeFile = b.Bytes()
+	plan.Project = Project{
+		Name:        projectName,
+		ComposeFile: plan.Result.ComposeFile,
+		ResultPath:  plan.Result.Path,
+		Images:      images.list(runCfg.NeedsRelay),
+
+		Keep:             benchmarkCfg.Keep,
+		LoadGenContainer: data.ContainerName("loadgen"),
+	}
+	plan.LoadGenArgs = data.LoadGenArgs()
+	return plan, nil
+}
+
+// run runs a single app. It returns a result even if the run fails, such that
+// the failure can be recorded in the result directory.
+func run(ctx context.Context, o Orchestrator, benchmarkCfg BenchmarkConfig, runCfg RunConfig) (*RunResult, error) {
+	oldprefix := log.Prefix()
+	defer log.SetPrefix(oldprefix)
+	logName := path.Join(append(strings.Split(benchmarkCfg.Platform, string(os.PathSeparator))[1:], runCfg.Name)...)
+	if benchmarkCfg.PlatformConfig.IsSweep() {
+		logName = fmt.Sprintf("%s@%drps", logName, runCfg.RPS)
+	}
+	if runCfg.Repetition > 0 {
+		logName = fmt.Sprintf("%s #%d", logName, runCfg.Repetition)
 	}
+	log.SetPrefix(fmt.Sprintf("%s[%s] ", oldprefix, logName))
+
+	log.Print("START")
+	defer log.Print("END")
 
-	result := &RunResult{
-		Name:        runCfg.Name,
-		ComposeFile: b.Bytes(),
-		Path:        filepath.Join("result", filepath.Join(strings.Split(resultPath, "/")...)),
+	plan, err := planRun(benchmarkCfg, runCfg)
+	result, project := plan.Result, plan.Project
+	if err != nil {
+		return result, err
 	}
 
 	if err := os.MkdirAll(result.Path, 0777); err != nil {
-		panic(err)
+		return result, err
 	}
 	if err := os.WriteFile(filepath.Join(result.Path, "docker-compose.yml"), result.ComposeFile, 0666); err != nil {
-		panic(err)
+		return result, err
+	}
+	if targets := benchmarkCfg.PlatformConfig.Targets; len(targets) > 0 {
+		// loadgen matches JSON keys case-insensitively and defaults to
+		// GET requests, like the platform config.
+		b, err := json.MarshalIndent(targets, "", "  ")
+		if err != nil {
+			return result, err
+		}
+		if err := os.WriteFile(filepath.Join(result.Path, "targets.json"), b, 0666); err != nil {
+			return result, err
+		}
 	}
 
-	defer composeDown(projectName)
-	composeBuild(ctx, projectName, result.ComposeFile)
-	composeUp(ctx, projectName, result.ComposeFile, filepath.Join(result.Path, "docker-compose-up.log"))
+	var kept bool
+	defer func() {
+		if kept {
+			return
+		}
+		if err := o.Down(project); err != nil {
+			log.Printf("Could not clean up: %s", err)
+		}
+	}()
+	// Builds are stopped at the session deadline, but not at the run
+	// timeout, which limits the test itself.
+	buildCtx := ctx
+	if !benchmarkCfg.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		buildCtx, cancel = context.WithDeadline(ctx, benchmarkCfg.Deadline)
+		defer cancel()
+	}
+	if err := build(buildCtx, o, project, benchmarkCfg.Rebuild); err != nil {
+		if ctx.Err() != nil {
+			return result, runError(StatusCanceled, ctx.Err())
+		}
+		if buildCtx.Err() != nil {
+			return result, runError(StatusTimedOut, errors.New("session timeout exceeded while building images"))
+		}
+		return result, runError(StatusBuildFailed, err)
+	}
+	deadline, why := benchmarkCfg.runDeadline(time.Now())
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return result, runError(StatusTimedOut, fmt.Errorf("%s exceeded before starting", why))
+	}
+	timedOut, err := upUntil(ctx, o, project, deadline)
+	if ctx.Err() != nil {
+		return result, runError(StatusCanceled, ctx.Err())
+	}
+	if merr := writeManifest(newManifest(ctx, o, benchmarkCfg, runCfg, plan.Images), result); merr != nil {
+		log.Printf("Could not write manifest: %s", merr)
+	}
+	if project.Keep {
+		// Keep the project even if the run failed, that is when it is
+		// most interesting to look into.
+		kept = true
+		result.Project = project.Name
+		printKept(ctx, o, benchmarkCfg, runCfg, project)
+	}
+	if timedOut || err != nil {
+		// The output of up may be incomplete, e.g. when the app exits
+		// before loadgen attaches. Keep the logs of all containers.
+		logPath := filepath.Join(result.Path, "docker-compose-logs.log")
+		if lerr := writeLogs(o, project, logPath); lerr != nil {
+			log.Printf("Could not save logs: %s", lerr)
+		}
+	}
+	if timedOut {
+		return result, runError(StatusTimedOut, fmt.Errorf("%s exceeded", why))
+	}
+	if err != nil {
+		return result, runError(StatusLoadGenFailed, err)
+	}
+	if _, err := os.Stat(filepath.Join(result.Path, "result.json")); err != nil {
+		return result, runError(StatusLoadGenFailed, fmt.Errorf("no result: %w", err))
+	}
 
-	return result
+	return result, nil
 }
 
-func findDockerfile(path string) string {
-	s, err := os.ReadDir(path)
-	if err != nil {
-		panic(err)
+// hasResult reports whether loadgen saved a result for r.
+func (r *RunResult) hasResult() bool {
+	_, err := os.Stat(filepath.Join(r.Path, "result.json"))
+	return err == nil
+}
+
+// runDeadline returns the time at which a run that starts its containers at
+// start must stop, and a description of the timeout that sets it. The time is
+// zero if there is no timeout.
+func (cfg BenchmarkConfig) runDeadline(start time.Time) (deadline time.Time, why string) {
+	if cfg.RunTimeout > 0 {
+		deadline, why = start.Add(cfg.RunTimeout), fmt.Sprintf("run timeout of %v", cfg.RunTimeout)
 	}
-	for _, entry := range s {
-		if !entry.IsDir() && strings.Contains(strings.ToLower(entry.Name()), "dockerfile") {
-			return entry.Name()
+	if !cfg.Deadline.IsZero() && (deadline.IsZero() || cfg.Deadline.Before(deadline)) {
+		deadline, why = cfg.Deadline, "session timeout"
+	}
+	return deadline, why
+}
+
+// loadgenStopTimeout is how long loadgen has to save partial results when it
+// is stopped early, before it is killed.
+const loadgenStopTimeout = 30 * time.Second
+
+// upUntil is like up, but stops loadgen at deadline, unless it is zero, such
+// that loadgen saves the results collected so far and the containers exit. It
+// reports whether the deadline was reached.
+func upUntil(ctx context.Context, o Orchestrator, p Project, deadline time.Time) (timedOut bool, err error) {
+	if deadline.IsZero() {
+		return false, up(ctx, o, p)
+	}
+	upCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		log.Print("Timed out, stopping loadgen")
+		if err := o.Stop(upCtx, p, "loadgen", loadgenStopTimeout); err != nil {
+			log.Printf("Could not stop loadgen: %s", err)
+		}
+		// Up returns when loadgen exits, unless something else hangs.
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			cancel()
 		}
+	})
+	err = up(upCtx, o, p)
+	close(done)
+	return !timer.Stop(), err
+}
+
+// printKept logs how to connect to the services of project p, which is left
+// running after the run.
+func printKept(ctx context.Context, o Orchestrator, cfg BenchmarkConfig, runCfg RunConfig, p Project) {
+	log.Printf("Keeping project %q running, tear it down with '%s down %s'", p.Name, filepath.Base(os.Args[0]), cfg.ID)
+	services := []struct {
+		Name string
+		Port int
+		Path string
+	}{
+		{"app", 8080, cfg.PlatformConfig.TargetPath()},
+		{"relay", 5000, ""},
+		{"cadvisor", 8080, ""},
+	}
+	for _, s := range services {
+		if s.Name == "relay" && !runCfg.NeedsRelay || s.Name == "cadvisor" && !usesCAdvisor(cfg.StatsSource) {
+			continue
+		}
+		addr, err := o.Port(ctx, p, s.Name, s.Port)
+		if err != nil {
+			log.Printf("  %-9s %s", s.Name+":", err)
+			continue
+		}
+		log.Printf("  %-9s http://%s%s", s.Name+":", addr, s.Path)
 	}
-	panic(fmt.Errorf("no Dockerfile in %s", path))
+	log.Printf("  %-9s %s", "compose:", filepath.Join(p.ResultPath, "docker-compose.yml"))
 }
 
-func composeBuild(ctx context.Context, projectName string, composeFile []byte) {
-	log.Print("Running 'docker compose build'...")
-	cmd := exec.CommandContext(
-		ctx,
-		"docker", "compose",
-		"--project-name", projectName,
-		"--file", "-",
-		"build",
-	)
-	cmd.Stdin = bytes.NewReader(composeFile)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		panic(err)
+// build builds the images of project p, unless all of them are cached.
+func build(ctx context.Context, o Orchestrator, p Project, rebuild bool) error {
+	if !rebuild {
+		cached, err := imagesCached(ctx, o, p.Images)
+		if err != nil {
+			log.Printf("Could not look up cached images: %s", err)
+		}
+		if cached {
+			log.Print("Using cached images")
+			return nil
+		}
 	}
+	return o.Build(ctx, p)
 }
 
-func composeUp(ctx context.Context, projectName string, composeFile []byte, outpath string) {
-	log.Printf("Running 'docker compose up', streaming logs to %q...", outpath)
+// up starts the containers of project p, streaming their output to the
+// terminal and to a log file in the result directory.
+func up(ctx context.Context, o Orchestrator, p Project) error {
+	outpath := filepath.Join(p.ResultPath, "docker-compose-up.log")
+	log.Printf("Starting containers, streaming logs to %q...", outpath)
 
 	out, err := os.Create(outpath)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer out.Close()
+	return o.Up(ctx, p, io.MultiWriter(out, os.Stdout))
+}
 
-	cmd := exec.CommandContext(
-		ctx,
-		"docker", "compose",
-		"--project-name", projectName,
-		"--file", "-",
-		"up", "--exit-code-from", "loadgen",
-	)
-	cmd.Stdin = bytes.NewReader(composeFile)
-	cmd.Stdout = io.MultiWriter(out, os.Stdout)
-	cmd.Stderr = io.MultiWriter(out, os.Stderr)
-	if err := cmd.Run(); err != nil {
-		panic(err)
+// writeLogs writes the output of all containers of project p to path.
+func writeLogs(o Orchestrator, p Project, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
+	return o.Logs(context.Background(), p, f)
 }
 
-func composeDown(projectName string) {
-	log.Print("Running 'docker compose down'...")
-	cmd := exec.Command(
-		"docker", "compose",
-		"--project-name", projectName,
-		"down", "--remove-orphans", "--rmi", "local")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		panic(err)
+var composeNameRegex = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// composeName returns s in a form valid in Docker Compose project, container
+// and image names, e.g. "instrumented@0.1" becomes "instrumented-0-1".
+func composeName(s string) string {
+	return composeNameRegex.ReplaceAllString(strings.ToLower(s), "-")
+}
+
+func findDockerfile(path string) (string, error) {
+	s, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range s {
+		if !entry.IsDir() && strings.Contains(strings.ToLower(entry.Name()), "dockerfile") {
+			return entry.Name(), nil
+		}
 	}
+	return "", fmt.Errorf("no Dockerfile in %s", path)
 }
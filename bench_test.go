@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -18,23 +20,27 @@ func TestBenchmarkConfigFromPath(t *testing.T) {
 			Target: struct{ Path string }{
 				Path: "/update?queries=10",
 			},
-			RPS:      10,
+			RPS:      Rates{10},
 			Duration: "30s",
 		},
 		Runs: []RunConfig{
 			{
 				Name:       "baseline",
+				App:        "baseline",
 				NeedsRelay: false,
 			},
 			{
 				Name:       "instrumented",
+				App:        "instrumented",
 				NeedsRelay: true,
 			},
 			{
 				Name:       "opentelemetry",
+				App:        "opentelemetry",
 				NeedsRelay: true,
 			},
 		},
+		Count: 1,
 	}
 	djangoInstrumented := BenchmarkConfig{
 		// ID: ...,
@@ -44,15 +50,77 @@ func TestBenchmarkConfigFromPath(t *testing.T) {
 			Target: struct{ Path string }{
 				Path: "/update?queries=10",
 			},
-			RPS:      10,
+			RPS:      Rates{10},
 			Duration: "30s",
 		},
 		Runs: []RunConfig{
 			{
 				Name:       "instrumented",
+				App:        "instrumented",
 				NeedsRelay: true,
 			},
 		},
+		Count: 1,
+	}
+	flaskVariants := []VariantConfig{
+		{
+			Name: "instrumented@0.1",
+			App:  "instrumented",
+			Env:  map[string]string{"SENTRY_TRACES_SAMPLE_RATE": "0.1"},
+		},
+	}
+	flaskAll := BenchmarkConfig{
+		// ID: ...,
+		// StartTime: ...,
+		Platform: "testdata/platform/python/flask",
+		PlatformConfig: PlatformConfig{
+			Target: struct{ Path string }{
+				Path: "/update?queries=10",
+			},
+			RPS:      Rates{10},
+			Duration: "30s",
+			Variants: flaskVariants,
+		},
+		Runs: []RunConfig{
+			{
+				Name:       "baseline",
+				App:        "baseline",
+				NeedsRelay: false,
+			},
+			{
+				Name:       "instrumented",
+				App:        "instrumented",
+				NeedsRelay: true,
+			},
+			{
+				Name:       "instrumented@0.1",
+				App:        "instrumented",
+				NeedsRelay: true,
+				Env:        map[string]string{"SENTRY_TRACES_SAMPLE_RATE": "0.1"},
+			},
+		},
+		Count: 1,
+	}
+	flaskBaseline := BenchmarkConfig{
+		// ID: ...,
+		// StartTime: ...,
+		Platform: "testdata/platform/python/flask",
+		PlatformConfig: PlatformConfig{
+			Target: struct{ Path string }{
+				Path: "/update?queries=10",
+			},
+			RPS:      Rates{10},
+			Duration: "30s",
+			Variants: flaskVariants,
+		},
+		Runs: []RunConfig{
+			{
+				Name:       "baseline",
+				App:        "baseline",
+				NeedsRelay: false,
+			},
+		},
+		Count: 1,
 	}
 	tests := []struct {
 		Path string
@@ -60,6 +128,8 @@ func TestBenchmarkConfigFromPath(t *testing.T) {
 	}{
 		{"testdata/platform/python/django", djangoAll},
 		{"testdata/platform/python/django/instrumented", djangoInstrumented},
+		{"testdata/platform/python/flask", flaskAll},
+		{"testdata/platform/python/flask/baseline", flaskBaseline},
 		// ensure trailing slash makes no difference
 		{"testdata/platform/python/django/", djangoAll},
 		{"testdata/platform/python/django/instrumented/", djangoInstrumented},
@@ -70,10 +140,555 @@ func TestBenchmarkConfigFromPath(t *testing.T) {
 		name := strings.TrimPrefix(tt.Path, "testdata/platform/")
 		t.Run(name, func(t *testing.T) {
 			want := tt.Want
-			got := BenchmarkConfigFromPath(filepath.FromSlash(tt.Path))
+			got, err := BenchmarkConfigFromPath(filepath.FromSlash(tt.Path))
+			if err != nil {
+				t.Fatal(err)
+			}
 			if diff := cmp.Diff(want, got, opts); diff != "" {
 				t.Errorf("(-want +got):\n%s", diff)
 			}
 		})
 	}
 }
+
+func TestBenchmarkConfigFromPathError(t *testing.T) {
+	tests := []string{
+		"testdata/platform/python/missing",
+		"testdata/platform/python",
+	}
+	for _, path := range tests {
+		if _, err := BenchmarkConfigFromPath(filepath.FromSlash(path)); err == nil {
+			t.Errorf("BenchmarkConfigFromPath(%q): got nil error", path)
+		}
+	}
+}
+
+func TestBenchmarkConfigSchedule(t *testing.T) {
+	runs := []RunConfig{
+		{Name: "baseline"},
+		{Name: "instrumented", NeedsRelay: true},
+		{Name: "opentelemetry", NeedsRelay: true},
+	}
+	tests := []struct {
+		Name  string
+		Count int
+		RPS   Rates
+		Want  []RunConfig
+	}{
+		{
+			"single",
+			1,
+			Rates{10},
+			[]RunConfig{
+				{Name: "baseline", RPS: 10},
+				{Name: "instrumented", NeedsRelay: true, RPS: 10},
+				{Name: "opentelemetry", NeedsRelay: true, RPS: 10},
+			},
+		},
+		{
+			"repeated",
+			3,
+			Rates{10},
+			[]RunConfig{
+				{Name: "baseline", Repetition: 1, RPS: 10},
+				{Name: "instrumented", NeedsRelay: true, Repetition: 1, RPS: 10},
+				{Name: "opentelemetry", NeedsRelay: true, Repetition: 1, RPS: 10},
+				{Name: "opentelemetry", NeedsRelay: true, Repetition: 2, RPS: 10},
+				{Name: "baseline", Repetition: 2, RPS: 10},
+				{Name: "instrumented", NeedsRelay: true, Repetition: 2, RPS: 10},
+				{Name: "instrumented", NeedsRelay: true, Repetition: 3, RPS: 10},
+				{Name: "opentelemetry", NeedsRelay: true, Repetition: 3, RPS: 10},
+				{Name: "baseline", Repetition: 3, RPS: 10},
+			},
+		},
+		{
+			"sweep",
+			1,
+			Rates{10, 100},
+			[]RunConfig{
+				{Name: "baseline", RPS: 10},
+				{Name: "instrumented", NeedsRelay: true, RPS: 10},
+				{Name: "opentelemetry", NeedsRelay: true, RPS: 10},
+				{Name: "baseline", RPS: 100},
+				{Name: "instrumented", NeedsRelay: true, RPS: 100},
+				{Name: "opentelemetry", NeedsRelay: true, RPS: 100},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			cfg := BenchmarkConfig{Runs: runs, Count: tt.Count}
+			cfg.PlatformConfig.RPS = tt.RPS
+			got := cfg.Schedule()
+			if diff := cmp.Diff(tt.Want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseRates(t *testing.T) {
+	tests := []struct {
+		In      string
+		Want    Rates
+		WantErr bool
+	}{
+		{In: "10", Want: Rates{10}},
+		{In: "10,50,100", Want: Rates{10, 50, 100}},
+		{In: "10-50/10", Want: Rates{10, 20, 30, 40, 50}},
+		{In: "1,10-30/10,100", Want: Rates{1, 10, 20, 30, 100}},
+		{In: "", WantErr: true},
+		{In: "abc", WantErr: true},
+		{In: "10-50", WantErr: true},
+		{In: "50-10/10", WantErr: true},
+		{In: "10-50/0", WantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.In, func(t *testing.T) {
+			got, err := ParseRates(tt.In)
+			if (err != nil) != tt.WantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.WantErr)
+			}
+			if diff := cmp.Diff(tt.Want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRatesUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		In      string
+		Want    Rates
+		WantErr string
+	}{
+		{In: `10`, Want: Rates{10}},
+		{In: `[10, 100]`, Want: Rates{10, 100}},
+		{In: `"10-30/10"`, Want: Rates{10, 20, 30}},
+		{In: `65535`, Want: Rates{65535}},
+		{In: `70000`, WantErr: `invalid rate: "70000"`},
+		{In: `-5`, WantErr: `invalid rate: "-5"`},
+		{In: `2.5`, WantErr: `invalid rate: "2.5"`},
+		{In: `[10, 70000]`, WantErr: `invalid rate: "70000"`},
+		{In: `[10, 2.5]`, WantErr: `invalid rate: "2.5"`},
+		{In: `["10"]`, WantErr: `invalid rates: ["10"]`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.In, func(t *testing.T) {
+			var got Rates
+			err := json.Unmarshal([]byte(tt.In), &got)
+			if tt.WantErr != "" {
+				if err == nil || err.Error() != tt.WantErr {
+					t.Fatalf("err = %v, want %s", err, tt.WantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.Want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	tests := []struct {
+		Profile LoadProfile
+		Want    string
+		WantErr bool
+	}{
+		{Profile: LoadProfile{Type: "ramp", To: 100}, Want: "ramp:to=100"},
+		{Profile: LoadProfile{Type: "steps", Step: 10, Every: "10s"}, Want: "steps:step=10,every=10s"},
+		{Profile: LoadProfile{Type: "spike", Peak: 100, Every: "10s", Length: "2s"}, Want: "spike:peak=100,every=10s,length=2s"},
+		{Profile: LoadProfile{Type: "sine", Amp: 5, Period: "30s"}, Want: "sine:amp=5,period=30s"},
+		{Profile: LoadProfile{Type: "steps", Step: 10}, Want: "steps:step=10,every=", WantErr: true},
+		{Profile: LoadProfile{Type: "spike", Peak: 100, Every: "1s", Length: "2s"}, Want: "spike:peak=100,every=1s,length=2s", WantErr: true},
+		{Profile: LoadProfile{Type: "sine", Amp: 50, Period: "30s"}, Want: "sine:amp=50,period=30s", WantErr: true},
+		{Profile: LoadProfile{Type: "ramp"}, Want: "ramp:to=0", WantErr: true},
+		{Profile: LoadProfile{Type: "ramp", To: 10}, Want: "ramp:to=10", WantErr: true},
+		{Profile: LoadProfile{Type: "ramp", To: 5}, Want: "ramp:to=5"},
+		{Profile: LoadProfile{Type: "steps", Every: "10s"}, Want: "steps:step=0,every=10s", WantErr: true},
+		{Profile: LoadProfile{Type: "spike", Peak: 10, Every: "10s", Length: "2s"}, Want: "spike:peak=10,every=10s,length=2s", WantErr: true},
+		{Profile: LoadProfile{Type: "square"}, Want: "square", WantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.Want, func(t *testing.T) {
+			if got := tt.Profile.String(); got != tt.Want {
+				t.Errorf("String() = %q, want %q", got, tt.Want)
+			}
+			err := tt.Profile.validate(Rates{10})
+			if (err != nil) != tt.WantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.WantErr)
+			}
+		})
+	}
+}
+
+func TestComposeName(t *testing.T) {
+	tests := []struct {
+		In   string
+		Want string
+	}{
+		{"baseline", "baseline"},
+		{"instrumented@0.1", "instrumented-0-1"},
+		{"Instrumented PII", "instrumented-pii"},
+		{"sdk_1.5", "sdk_1-5"},
+	}
+	for _, tt := range tests {
+		if got := composeName(tt.In); got != tt.Want {
+			t.Errorf("composeName(%q) = %q, want %q", tt.In, got, tt.Want)
+		}
+	}
+}
+
+func TestBenchmarkConfigRunDeadline(t *testing.T) {
+	start := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		Name       string
+		RunTimeout time.Duration
+		Deadline   time.Time
+		Want       time.Time
+		WantWhy    string
+	}{
+		{Name: "none"},
+		{
+			Name:       "run",
+			RunTimeout: 10 * time.Minute,
+			Want:       start.Add(10 * time.Minute),
+			WantWhy:    "run timeout of 10m0s",
+		},
+		{
+			Name:     "session",
+			Deadline: start.Add(time.Hour),
+			Want:     start.Add(time.Hour),
+			WantWhy:  "session timeout",
+		},
+		{
+			Name:       "run before session",
+			RunTimeout: 10 * time.Minute,
+			Deadline:   start.Add(time.Hour),
+			Want:       start.Add(10 * time.Minute),
+			WantWhy:    "run timeout of 10m0s",
+		},
+		{
+			Name:       "session before run",
+			RunTimeout: 10 * time.Minute,
+			Deadline:   start.Add(5 * time.Minute),
+			Want:       start.Add(5 * time.Minute),
+			WantWhy:    "session timeout",
+		},
+	}
+	for _, tt := range tests {
+		cfg := BenchmarkConfig{RunTimeout: tt.RunTimeout, Deadline: tt.Deadline}
+		got, why := cfg.runDeadline(start)
+		if !got.Equal(tt.Want) || why != tt.WantWhy {
+			t.Errorf("%s: got %v (%q), want %v (%q)", tt.Name, got, why, tt.Want, tt.WantWhy)
+		}
+	}
+}
+
+func TestDockerComposeDataLoadGenArgs(t *testing.T) {
+	base := PlatformConfig{RPS: Rates{10}, Duration: "30s"}
+	base.Target.Path = "/update?queries=10"
+	closed := base
+	closed.Model = "closed"
+	closed.Concurrency = 8
+	closed.ThinkTime = "10ms"
+	full := base
+	full.Targets = []TargetConfig{{Path: "/fortunes", Weight: 1}}
+	full.MaxWait = "1m"
+	full.Warmup = "5s"
+	full.Profile = &LoadProfile{Type: "ramp", To: 100}
+	full.Capacity = &CapacityConfig{Success: 0.99, P99: "100ms", Step: "5s", Max: 500}
+	id := BenchmarkID{1, 2, 3, 4}
+	tests := []struct {
+		Name string
+		Data DockerComposeData
+		Want []string
+	}{
+		{
+			"baseline",
+			DockerComposeData{ID: id, RunName: "baseline", PlatformConfig: base, RPS: 10, ResultPath: "python/django/x/baseline"},
+			[]string{
+				"-target", "http://app:8080/update?queries=10",
+				"-rps", "10",
+				"-test", "30s",
+				"-cadvisor", "http://cadvisor:8080",
+				"-containers", "app-baseline-aebagba,postgres-baseline-aebagba,loadgen-baseline-aebagba,cadvisor-baseline-aebagba",
+				"-out", "/result/python/django/x/baseline",
+			},
+		},
+		{
+			"closed",
+			DockerComposeData{ID: id, RunName: "instrumented", PlatformConfig: closed, RPS: 10, ResultPath: "x", NeedsRelay: true},
+			[]string{
+				"-target", "http://app:8080/update?queries=10",
+				"-concurrency", "8",
+				"-think", "10ms",
+				"-test", "30s",
+				"-cadvisor", "http://cadvisor:8080",
+				"-fakerelay", "http://relay:5000",
+				"-containers", "app-instrumented-aebagba,postgres-instrumented-aebagba,loadgen-instrumented-aebagba,cadvisor-instrumented-aebagba,fakerelay-instrumented-aebagba",
+				"-out", "/result/x",
+			},
+		},
+		{
+			"full",
+			DockerComposeData{ID: id, RunName: "baseline", PlatformConfig: full, RPS: 10, ResultPath: "x"},
+			[]string{
+				"-target", "http://app:8080/update?queries=10",
+				"-targets", "/result/x/targets.json",
+				"-rps", "10",
+				"-profile", "ramp:to=100",
+				"-test", "30s",
+				"-maxwait", "1m",
+				"-capacity", "-slo-success", "0.99", "-slo-p99", "100ms", "-capacity-step", "5s", "-capacity-max", "500",
+				"-warmup", "5s",
+				"-cadvisor", "http://cadvisor:8080",
+				"-containers", "app-baseline-aebagba,postgres-baseline-aebagba,loadgen-baseline-aebagba,cadvisor-baseline-aebagba",
+				"-out", "/result/x",
+			},
+		},
+		{
+			"docker stats",
+			DockerComposeData{ID: id, RunName: "baseline", PlatformConfig: base, RPS: 10, ResultPath: "x", StatsSource: "docker"},
+			[]string{
+				"-target", "http://app:8080/update?queries=10",
+				"-rps", "10",
+				"-test", "30s",
+				"-stats", "docker",
+				"-containers", "app-baseline-aebagba,postgres-baseline-aebagba,loadgen-baseline-aebagba",
+				"-out", "/result/x",
+			},
+		},
+		{
+			"cgroup stats",
+			DockerComposeData{ID: id, RunName: "baseline", PlatformConfig: base, RPS: 10, ResultPath: "x", StatsSource: "cgroup"},
+			[]string{
+				"-target", "http://app:8080/update?queries=10",
+				"-rps", "10",
+				"-test", "30s",
+				"-stats", "cgroup", "-cgroup", "/host/sys/fs/cgroup", "-proc", "/host/proc",
+				"-containers", "app-baseline-aebagba,postgres-baseline-aebagba,loadgen-baseline-aebagba",
+				"-out", "/result/x",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.Want, tt.Data.LoadGenArgs()); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPlanRunStatsSource(t *testing.T) {
+	cfg, err := BenchmarkConfigFromPath(filepath.FromSlash("testdata/platform/python/flask"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range StatsSources {
+		cfg.StatsSource = source
+		plan, err := planRun(cfg, cfg.Runs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		compose := string(plan.Result.ComposeFile)
+		hasCAdvisor := strings.Contains(compose, "  cadvisor:\n")
+		if want := source == "cadvisor"; hasCAdvisor != want || (plan.Images.CAdvisor != "") != want {
+			t.Errorf("%s: got cAdvisor service %v and image %q, want cAdvisor %v", source, hasCAdvisor, plan.Images.CAdvisor, want)
+		}
+		if got, want := strings.Contains(compose, "/var/run/docker.sock:/var/run/docker.sock"), true; got != want {
+			t.Errorf("%s: got Docker socket mounted %v, want %v", source, got, want)
+		}
+		if got, want := strings.Contains(compose, "/sys/fs/cgroup:/host/sys/fs/cgroup:ro") && strings.Contains(compose, "/proc:/host/proc:ro"), source == "cgroup"; got != want {
+			t.Errorf("%s: got cgroup hierarchy mounted %v, want %v", source, got, want)
+		}
+	}
+}
+
+func TestSDKVersionsConfigRuns(t *testing.T) {
+	c := SDKVersionsConfig{Versions: []string{"1.4.3", "1.5.0"}}
+	want := []RunConfig{
+		{
+			Name:       "instrumented@1.4.3",
+			App:        "instrumented",
+			NeedsRelay: true,
+			BuildArgs:  map[string]string{"SENTRY_SDK_VERSION": "1.4.3"},
+		},
+		{
+			Name:       "instrumented@1.5.0",
+			App:        "instrumented",
+			NeedsRelay: true,
+			BuildArgs:  map[string]string{"SENTRY_SDK_VERSION": "1.5.0"},
+		},
+	}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, c.runs()); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func TestBenchmarkConfigOverride(t *testing.T) {
+	var cfg BenchmarkConfig
+	cfg.PlatformConfig.Targets = []TargetConfig{{Path: "/fortunes", Weight: 1}}
+	for _, o := range [][2]string{
+		{"rps", "10,20"},
+		{"duration", "1m"},
+		{"warmup", "0s"},
+		{"maxwait", "2m"},
+		{"target", "/plaintext"},
+	} {
+		if err := cfg.Override(o[0], o[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := PlatformConfig{
+		Target: struct{ Path string }{
+			Path: "/plaintext",
+		},
+		RPS:      Rates{10, 20},
+		Duration: "1m",
+		Warmup:   "0s",
+		MaxWait:  "2m",
+	}
+	if diff := cmp.Diff(want, cfg.PlatformConfig); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+	if len(cfg.Overrides) != 5 {
+		t.Errorf("got %d overrides, want 5", len(cfg.Overrides))
+	}
+	if err := cfg.Override("rps", "abc"); err == nil {
+		t.Error("invalid rps: got nil error")
+	}
+	if err := cfg.Override("model", "closed"); err == nil {
+		t.Error("unknown override: got nil error")
+	}
+}
+
+func TestVariantConfigValidate(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Env     map[string]string
+		WantErr bool
+	}{
+		{Name: "instrumented-no-tracing"},
+		{Name: "v2"},
+		{Name: "fast-rps"},
+		{Name: "sample-rate", Env: map[string]string{"SENTRY_TRACES_SAMPLE_RATE": "0.1"}},
+		{Name: "", WantErr: true},
+		{Name: "a/b", WantErr: true},
+		{Name: "2", WantErr: true},
+		{Name: "50rps", WantErr: true},
+		{Name: "bad-env", Env: map[string]string{"1X": "1"}, WantErr: true},
+		{Name: "dsn", Env: map[string]string{"SENTRY_DSN": "http://sentry@example.com/1"}, WantErr: true},
+		{Name: "zipkin", Env: map[string]string{"OTEL_EXPORTER_ZIPKIN_ENDPOINT": "http://example.com"}, WantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			err := VariantConfig{Name: tt.Name, App: "instrumented", Env: tt.Env}.validate()
+			if (err != nil) != tt.WantErr {
+				t.Errorf("err = %v, want error: %v", err, tt.WantErr)
+			}
+		})
+	}
+}
+
+func TestPlatformConfigValidateVariants(t *testing.T) {
+	sdkVersions := &SDKVersionsConfig{Versions: []string{"1.5.0"}}
+	tests := []struct {
+		Name        string
+		Variants    []VariantConfig
+		SDKVersions *SDKVersionsConfig
+		WantErr     bool
+	}{
+		{
+			Name:     "distinct",
+			Variants: []VariantConfig{{Name: "a", App: "instrumented"}, {Name: "b", App: "instrumented"}},
+		},
+		{
+			Name:     "duplicate",
+			Variants: []VariantConfig{{Name: "a", App: "instrumented"}, {Name: "a", App: "instrumented"}},
+			WantErr:  true,
+		},
+		{
+			Name:     "duplicate compose name",
+			Variants: []VariantConfig{{Name: "v@1", App: "instrumented"}, {Name: "v-1", App: "instrumented"}},
+			WantErr:  true,
+		},
+		{
+			Name:        "sdk version run",
+			Variants:    []VariantConfig{{Name: "instrumented@1.5.0", App: "instrumented"}},
+			SDKVersions: sdkVersions,
+			WantErr:     true,
+		},
+		{
+			Name:        "sdk version build arg",
+			Variants:    []VariantConfig{{Name: "pinned", App: "instrumented", BuildArgs: map[string]string{"SENTRY_SDK_VERSION": "1.0.0"}}},
+			SDKVersions: sdkVersions,
+			WantErr:     true,
+		},
+		{
+			Name:        "other build arg",
+			Variants:    []VariantConfig{{Name: "debug", App: "instrumented", BuildArgs: map[string]string{"DEBUG": "1"}}},
+			SDKVersions: sdkVersions,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			cfg := PlatformConfig{
+				RPS:         Rates{10},
+				Duration:    "10s",
+				Variants:    tt.Variants,
+				SDKVersions: tt.SDKVersions,
+			}
+			cfg.Target.Path = "/"
+			err := cfg.Validate()
+			if (err != nil) != tt.WantErr {
+				t.Errorf("err = %v, want error: %v", err, tt.WantErr)
+			}
+		})
+	}
+}
+
+func TestPlatformConfigValidateClosedModel(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Modify  func(*PlatformConfig)
+		WantErr bool
+	}{
+		{Name: "closed", Modify: func(*PlatformConfig) {}},
+		{Name: "sweep", Modify: func(cfg *PlatformConfig) { cfg.RPS = Rates{10, 20} }, WantErr: true},
+		{Name: "profile", Modify: func(cfg *PlatformConfig) { cfg.Profile = &LoadProfile{Type: "ramp", To: 20} }, WantErr: true},
+		{Name: "capacity", Modify: func(cfg *PlatformConfig) { cfg.Capacity = &CapacityConfig{Success: 0.99, P99: "1s"} }, WantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			cfg := PlatformConfig{
+				RPS:         Rates{10},
+				Duration:    "10s",
+				Model:       "closed",
+				Concurrency: 4,
+			}
+			cfg.Target.Path = "/"
+			tt.Modify(&cfg)
+			err := cfg.Validate()
+			if (err != nil) != tt.WantErr {
+				t.Errorf("err = %v, want error: %v", err, tt.WantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+)
+
+// Images holds the references of the images of the services of a run.
+//
+// Images built by the runner are content-addressed: the tag of an image is a
+// hash of its build context, such that an image built for one run can be
+// reused by any later run with the same build context.
+type Images struct {
+	App      string
+	LoadGen  string
+	Relay    string
+	Database string
+	CAdvisor string // pulled, not built
+}
+
+// cadvisorImage is the image of cAdvisor, pulled from a registry.
+const cadvisorImage = "gcr.io/cadvisor/cadvisor:v0.37.5"
+
+// list returns the references of the images built for a run.
+func (i Images) list(needsRelay bool) []string {
+	refs := []string{i.App, i.LoadGen, i.Database}
+	if needsRelay {
+		refs = append(refs, i.Relay)
+	}
+	return refs
+}
+
+// imageLabel is the label of all images built by the runner.
+const imageLabel = "io.sentry.sentry-sdk-benchmark"
+
+// Build contexts of the auxiliary services.
+var (
+	loadgenContext  = filepath.Join("tool", "loadgen")
+	relayContext    = filepath.Join("tool", "fakerelay")
+	databaseContext = filepath.Join("tool", "database", "postgres")
+)
+
+// planImages returns the images of a run of the app with the given build
+// context, Dockerfile and build arguments.
+func planImages(language, framework, app, contextPath, dockerfile string, buildArgs map[string]string) (Images, error) {
+	images := Images{CAdvisor: cadvisorImage}
+	var err error
+	ref := func(name, dir, dockerfile string, buildArgs map[string]string) string {
+		if err != nil {
+			return ""
+		}
+		var hash string
+		hash, err = contextHash(dir, dockerfile, buildArgs)
+		return fmt.Sprintf("sentry-sdk-benchmark/%s:%s", name, hash)
+	}
+	images.App = ref(fmt.Sprintf("app-%s-%s-%s", composeName(language), composeName(framework), composeName(app)), contextPath, dockerfile, buildArgs)
+	images.LoadGen = ref("loadgen", loadgenContext, "Dockerfile", nil)
+	images.Relay = ref("relay", relayContext, "Dockerfile", nil)
+	images.Database = ref("postgres", databaseContext, "postgres.dockerfile", nil)
+	return images, err
+}
+
+// contextHash returns a hash of a Docker build context, covering the names,
+// modes and contents of all files in dir, the name of the Dockerfile and the
+// build arguments.
+func contextHash(dir, dockerfile string, buildArgs map[string]string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "dockerfile %q\n", dockerfile)
+	keys := make([]string, 0, len(buildArgs))
+	for k := range buildArgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "arg %q=%q\n", k, buildArgs[k])
+	}
+	// WalkDir visits files in lexical order, so the hash is deterministic.
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "file %q %v\n", filepath.ToSlash(rel), info.Mode())
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "link %q\n", target)
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// imagesCached reports whether all images exist locally.
+func imagesCached(ctx context.Context, o Orchestrator, refs []string) (bool, error) {
+	for _, ref := range refs {
+		ok, err := o.ImageExists(ctx, ref)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Image is an image built by the runner.
+type Image struct {
+	Ref     string // like "sentry-sdk-benchmark/loadgen:0123456789ab"
+	ID      string
+	Digest  string // repository digest, empty for images that were never pushed or pulled
+	Created string
+	Size    string
+}
+
+// Cache lists the cached images or, if prune is true, removes them.
+func Cache(ctx context.Context, o Orchestrator, w io.Writer, prune bool) error {
+	images, err := o.Images(ctx)
+	if err != nil {
+		return err
+	}
+	if prune {
+		if len(images) == 0 {
+			fmt.Fprintln(w, "No cached images")
+			return nil
+		}
+		refs := make([]string, len(images))
+		for i, img := range images {
+			refs[i] = img.Ref
+		}
+		if err := o.RemoveImages(ctx, refs); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Removed %d cached images\n", len(images))
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMAGE\tID\tCREATED\tSIZE")
+	for _, img := range images {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", img.Ref, img.ID, img.Created, img.Size)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContextHash(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func(dockerfile string, buildArgs map[string]string) string {
+		t.Helper()
+		h, err := contextHash(dir, dockerfile, buildArgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	write("Dockerfile", "FROM python:3.9\n")
+	write("app/main.py", "print('hello')\n")
+
+	h := hash("Dockerfile", nil)
+	if len(h) != 12 {
+		t.Errorf("got hash %q, want 12 hex digits", h)
+	}
+	if got := hash("Dockerfile", nil); got != h {
+		t.Errorf("hash is not deterministic: %q != %q", got, h)
+	}
+	args := hash("Dockerfile", map[string]string{"SENTRY_SDK_VERSION": "1.5.0"})
+	if args == h {
+		t.Errorf("build arguments do not change the hash")
+	}
+	if got := hash("Dockerfile", map[string]string{"SENTRY_SDK_VERSION": "1.5.0"}); got != args {
+		t.Errorf("hash with build arguments is not deterministic: %q != %q", got, args)
+	}
+	if got := hash("other.dockerfile", nil); got == h {
+		t.Errorf("Dockerfile name does not change the hash")
+	}
+	write("app/main.py", "print('hello, world')\n")
+	if got := hash("Dockerfile", nil); got == h {
+		t.Errorf("file content does not change the hash")
+	}
+}
@@ -18,7 +18,8 @@ func Compare(resultPaths []string) {
 	m := make(map[string][]byte)
 
 	// Walk each directory from resultPaths and collect benchmark data from
-	// all result.json files.
+	// all result.json files. Repetitions of a run are aggregated under the
+	// same name, while runs at different request rates are kept apart.
 	for _, root := range resultPaths {
 		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
@@ -27,12 +28,13 @@ func Compare(resultPaths []string) {
 			if !d.IsDir() {
 				return nil
 			}
-			defer func() {
-				// ignore panics from readTestResult
-				_ = recover()
-			}()
-			tr := readTestResult(filepath.Join(path, "result.json"))
-			m[d.Name()] = append(m[d.Name()], toGoBenchFormat(tr)...)
+			tr, err := readTestResult(filepath.Join(path, "result.json"))
+			if err != nil {
+				// not a run directory, or a failed run
+				return nil
+			}
+			name := compareName(path)
+			m[name] = append(m[name], toGoBenchFormat(tr)...)
 			return nil
 		})
 	}
@@ -52,6 +54,17 @@ func Compare(resultPaths []string) {
 	benchstat.FormatText(os.Stdout, c.Tables())
 }
 
+// compareName returns the name of the run stored in path. Runs at different
+// request rates of a sweep are named after their rate, like "100rps/baseline".
+func compareName(path string) string {
+	name := filepath.Base(path)
+	dir := filepath.Base(filepath.Dir(path))
+	if _, ok := parseRPSDir(dir); ok {
+		return dir + "/" + name
+	}
+	return name
+}
+
 func toGoBenchFormat(tr TestResult) []byte {
 	var b bytes.Buffer
 	writeln := func(name string, value interface{}, unit string) {
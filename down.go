@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Down tears down the projects left running by benchmarks run with -keep. Each
+// arg is either a benchmark ID, like "tbnfsga", or a result directory, like
+// "result/python/django/20210818-082527-tbnfsga".
+func Down(o Orchestrator, w io.Writer, args []string) error {
+	var n int
+	for _, arg := range args {
+		roots, err := findResultDirs(arg)
+		if err != nil {
+			return err
+		}
+		for _, root := range roots {
+			err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() || d.Name() != "status.json" {
+					return nil
+				}
+				ok, err := downRun(o, filepath.Dir(path))
+				if ok {
+					fmt.Fprintf(w, "Tore down %s\n", filepath.Dir(path))
+					n++
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if n == 0 {
+		fmt.Fprintln(w, "No kept projects")
+	}
+	return nil
+}
+
+// findResultDirs returns the result directories of a benchmark, given its ID
+// or a result directory.
+func findResultDirs(arg string) ([]string, error) {
+	if fi, err := os.Stat(arg); err == nil && fi.IsDir() {
+		return []string{arg}, nil
+	}
+	if strings.ContainsRune(arg, os.PathSeparator) {
+		return nil, fmt.Errorf("%s: no such result directory", arg)
+	}
+	var dirs []string
+	err := filepath.WalkDir("result", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasSuffix(d.Name(), "-"+arg) {
+			dirs = append(dirs, path)
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no result directory for benchmark ID %q", arg)
+	}
+	return dirs, nil
+}
+
+// downRun tears down the project of the run with results in path, if it was
+// kept. It reports whether a project was torn down.
+func downRun(o Orchestrator, path string) (bool, error) {
+	statusPath := filepath.Join(path, "status.json")
+	b, err := os.ReadFile(statusPath)
+	if err != nil {
+		return false, err
+	}
+	var s statusFile
+	if err := json.Unmarshal(b, &s); err != nil {
+		return false, fmt.Errorf("%s: %w", statusPath, err)
+	}
+	if s.Project == "" {
+		return false, nil
+	}
+	composeFile, err := os.ReadFile(filepath.Join(path, "docker-compose.yml"))
+	if err != nil {
+		return false, err
+	}
+	if err := o.Down(Project{Name: s.Project, ComposeFile: composeFile, ResultPath: path}); err != nil {
+		return false, fmt.Errorf("down %s: %w", s.Project, err)
+	}
+	// Forget the project, such that tearing down again is a no-op.
+	s.Project = ""
+	b, err = json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return true, err
+	}
+	return true, os.WriteFile(statusPath, b, 0666)
+}
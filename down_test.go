@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBenchmarkKeepAndDown(t *testing.T) {
+	defer func(b bool) { openBrowser = b }(openBrowser)
+	openBrowser = false
+
+	platform := chdirPlatform(t, "baseline", "instrumented")
+	cfg, err := BenchmarkConfigFromPath(platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Keep = true
+	o := &fakeOrchestrator{Files: fakeResultFiles(t, 20)}
+	results, err := Benchmark(context.Background(), o, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := filterCalls(o.Calls, "down "); len(calls) != 0 {
+		t.Errorf("kept runs were torn down: %v", calls)
+	}
+	for _, res := range results {
+		if res.Project == "" {
+			t.Errorf("%s: project not recorded", res.Name)
+		}
+		b, err := os.ReadFile(filepath.Join(res.Path, "docker-compose.yml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), `"127.0.0.1::8080"`) {
+			t.Errorf("%s: docker-compose.yml does not publish ports:\n%s", res.Name, b)
+		}
+	}
+
+	o.Calls = nil
+	var out bytes.Buffer
+	if err := Down(o, &out, []string{cfg.ID.String()}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"down baseline", "down instrumented"}, o.Calls); diff != "" {
+		t.Errorf("calls (-want +got):\n%s", diff)
+	}
+
+	// Tearing down again is a no-op.
+	o.Calls = nil
+	out.Reset()
+	if err := Down(o, &out, []string{cfg.ResultPath()}); err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Calls) != 0 || !strings.Contains(out.String(), "No kept projects") {
+		t.Errorf("second down: calls %v, output %q", o.Calls, out.String())
+	}
+
+	if err := Down(o, &out, []string{"nosuchid"}); err == nil {
+		t.Error("down of unknown ID: got nil error")
+	}
+}
@@ -54,6 +54,16 @@ type DygraphsOpts struct {
 	StrokeWidth float64  `json:"strokeWidth"`
 	Width       int      `json:"width,omitempty"`
 	RollPeriod  int      `json:"rollPeriod,omitempty"`
+	Y2Label     string   `json:"y2label,omitempty"`
+
+	ConnectSeparatedPoints bool `json:"connectSeparatedPoints,omitempty"`
+
+	Series map[string]DygraphsSeriesOpts `json:"series,omitempty"`
+}
+
+// DygraphsSeriesOpts configures per-series options for a Dygraph Chart.
+type DygraphsSeriesOpts struct {
+	Axis string `json:"axis,omitempty"` // "y" or "y2"
 }
 
 type ChartData struct {
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/url"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// EndpointLatency is the latency of one endpoint of a run, aggregated over all
+// repetitions.
+type EndpointLatency struct {
+	Endpoint string // like "GET /fortunes"
+	Weight   uint
+	Name     string
+	RPS      uint16
+	Metrics  vegeta.LatencyMetrics
+	Diff     *LatencyDiff
+}
+
+// getEndpointLatencies returns the latency of every endpoint of every group,
+// ordered by endpoint. Latencies are compared to the baseline of the same
+// endpoint and request rate.
+func getEndpointLatencies(groups []*runGroup) ([]EndpointLatency, error) {
+	var endpoints []string
+	weights := make(map[string]uint)
+	for _, g := range groups {
+		for _, tr := range g.TestResults {
+			for _, e := range tr.Endpoints {
+				if !contains(endpoints, e.Endpoint) {
+					endpoints = append(endpoints, e.Endpoint)
+					weights[e.Endpoint] = e.Weight
+				}
+			}
+		}
+	}
+
+	if len(endpoints) == 0 {
+		// Runs with a single target have no per-endpoint latencies,
+		// don't read their results.
+		return nil, nil
+	}
+
+	groupLatencies := make([]map[string]vegeta.LatencyMetrics, len(groups))
+	for i, g := range groups {
+		m, err := aggregateEndpointLatencies(g.TestResults)
+		if err != nil {
+			return nil, err
+		}
+		groupLatencies[i] = m
+	}
+
+	var latencies []EndpointLatency
+	for _, endpoint := range endpoints {
+		baselines := make(map[uint16]vegeta.LatencyMetrics)
+		for i, g := range groups {
+			m, ok := groupLatencies[i][endpoint]
+			if !ok {
+				continue
+			}
+			l := EndpointLatency{
+				Endpoint: endpoint,
+				Weight:   weights[endpoint],
+				Name:     g.Name,
+				RPS:      g.RPS,
+				Metrics:  m,
+			}
+			if g.Name == "baseline" {
+				baselines[g.RPS] = m
+			} else if baseline, ok := baselines[g.RPS]; ok {
+				l.Diff = getLatencyDiff(baseline, m)
+			}
+			latencies = append(latencies, l)
+		}
+	}
+	return latencies, nil
+}
+
+// aggregateEndpointLatencies returns latency metrics of every endpoint computed
+// over the results of all repetitions of a run, reading the results of each
+// repetition once.
+func aggregateEndpointLatencies(trs []TestResult) (map[string]vegeta.LatencyMetrics, error) {
+	latencies := make(map[string]vegeta.LatencyMetrics)
+	if len(trs) == 1 {
+		for _, e := range trs[0].Endpoints {
+			if e.Metrics != nil {
+				latencies[e.Endpoint] = e.Latencies
+			}
+		}
+		return latencies, nil
+	}
+	metrics := make(map[string]*vegeta.Metrics)
+	for _, tr := range trs {
+		err := tr.eachResult(func(r *vegeta.Result) {
+			endpoint := endpointName(r)
+			m, ok := metrics[endpoint]
+			if !ok {
+				m = &vegeta.Metrics{}
+				metrics[endpoint] = m
+			}
+			m.Add(r)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for endpoint, m := range metrics {
+		m.Close()
+		latencies[endpoint] = m.Latencies
+	}
+	return latencies, nil
+}
+
+// endpointName returns the name of the endpoint a result belongs to, like
+// "GET /fortunes".
+func endpointName(r *vegeta.Result) string {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return r.Method + " " + r.URL
+	}
+	return r.Method + " " + u.RequestURI()
+}
+
+// endpointLabeler labels results by endpoint, such that the latency of every
+// endpoint is plotted in a separate series. Errors of all endpoints are
+// plotted together.
+func endpointLabeler(r *vegeta.Result) string {
+	if r.Error != "" {
+		return "ERROR"
+	}
+	return endpointName(r)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func Test_endpointName(t *testing.T) {
+	tests := []struct {
+		r    vegeta.Result
+		want string
+	}{
+		{vegeta.Result{Method: "GET", URL: "http://app:8080/fortunes"}, "GET /fortunes"},
+		{vegeta.Result{Method: "POST", URL: "http://app:8080/update?queries=2"}, "POST /update?queries=2"},
+		{vegeta.Result{Method: "GET", URL: "http://app:8080/"}, "GET /"},
+		{vegeta.Result{Method: "GET", URL: "%zz"}, "GET %zz"},
+	}
+	for _, tt := range tests {
+		if got := endpointName(&tt.r); got != tt.want {
+			t.Errorf("endpointName(%q) = %q, want %q", tt.r.URL, got, tt.want)
+		}
+	}
+}
+
+func Test_getEndpointLatencies(t *testing.T) {
+	endpoint := func(name string, weight uint, p50 time.Duration) EndpointResult {
+		m := &vegeta.Metrics{Requests: 1}
+		m.Latencies.P50 = p50
+		return EndpointResult{Endpoint: name, Weight: weight, Metrics: m}
+	}
+	result := func(endpoints ...EndpointResult) TestResult {
+		return TestResult{Endpoints: endpoints}
+	}
+	// Repetitions are aggregated from the results of every request.
+	requests := func(fortunes time.Duration) TestResult {
+		var tr TestResult
+		for i := 0; i < 10; i++ {
+			tr.LoadGenResult = append(tr.LoadGenResult,
+				&vegeta.Result{Method: "GET", URL: "http://app:8080/fortunes", Latency: fortunes, Timestamp: time.Unix(int64(i), 0)},
+				&vegeta.Result{Method: "GET", URL: "http://app:8080/update", Latency: time.Millisecond, Timestamp: time.Unix(int64(i), 0)},
+			)
+		}
+		tr.Endpoints = []EndpointResult{endpoint("GET /fortunes", 3, 0), endpoint("GET /update", 1, 0)}
+		return tr
+	}
+	groups := []*runGroup{
+		{Name: "baseline", RPS: 10, TestResults: []TestResult{result(endpoint("GET /fortunes", 3, 10*time.Millisecond), endpoint("GET /update", 1, 20*time.Millisecond))}},
+		{Name: "instrumented", RPS: 10, TestResults: []TestResult{result(endpoint("GET /fortunes", 3, 15*time.Millisecond), endpoint("GET /update", 1, 20*time.Millisecond))}},
+		{Name: "baseline", RPS: 20, TestResults: []TestResult{requests(10 * time.Millisecond), requests(10 * time.Millisecond)}},
+		{Name: "instrumented", RPS: 20, TestResults: []TestResult{requests(20 * time.Millisecond), requests(20 * time.Millisecond)}},
+	}
+	got, err := getEndpointLatencies(groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 8 {
+		t.Fatalf("got %d rows, want 4 per endpoint: %+v", len(got), got)
+	}
+	for i, want := range []struct {
+		endpoint string
+		weight   uint
+		name     string
+		rps      uint16
+		p50      time.Duration
+		diff     float64 // of P50, negative for the baseline
+	}{
+		{"GET /fortunes", 3, "baseline", 10, 10 * time.Millisecond, -1},
+		{"GET /fortunes", 3, "instrumented", 10, 15 * time.Millisecond, 50},
+		{"GET /fortunes", 3, "baseline", 20, 10 * time.Millisecond, -1},
+		{"GET /fortunes", 3, "instrumented", 20, 20 * time.Millisecond, 100},
+		{"GET /update", 1, "baseline", 10, 20 * time.Millisecond, -1},
+		{"GET /update", 1, "instrumented", 10, 20 * time.Millisecond, 0},
+		{"GET /update", 1, "baseline", 20, time.Millisecond, -1},
+		{"GET /update", 1, "instrumented", 20, time.Millisecond, 0},
+	} {
+		l := got[i]
+		if l.Endpoint != want.endpoint || l.Weight != want.weight || l.Name != want.name || l.RPS != want.rps || l.Metrics.P50 != want.p50 {
+			t.Errorf("row %d = %s (weight %d) %s @ %d: p50 %v, want %s (weight %d) %s @ %d: p50 %v",
+				i, l.Endpoint, l.Weight, l.Name, l.RPS, l.Metrics.P50, want.endpoint, want.weight, want.name, want.rps, want.p50)
+		}
+		switch {
+		case want.diff < 0 && l.Diff != nil:
+			t.Errorf("row %d: got diff %+v for the baseline", i, *l.Diff)
+		case want.diff >= 0 && (l.Diff == nil || l.Diff.P50 != want.diff):
+			t.Errorf("row %d: got diff %+v, want P50 diff %v", i, l.Diff, want.diff)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	exec "github.com/getsentry/sentry-sdk-benchmark/internal/std/execabs"
+)
+
+// Environment describes the host that ran a benchmark. Facts that cannot be
+// determined are left empty, e.g. most host facts are only available on Linux.
+type Environment struct {
+	Hostname    string       `json:"hostname"`
+	Computer    string       `json:"computer,omitempty"` // vendor and model, from DMI
+	OS          string       `json:"os"`                 // e.g. "Ubuntu 20.04.3 LTS"
+	Kernel      string       `json:"kernel,omitempty"`
+	Arch        string       `json:"arch"`
+	CPU         CPUInfo      `json:"cpu"`
+	MemoryBytes uint64       `json:"memory_bytes,omitempty"` // total memory
+	Runtime     *RuntimeInfo `json:"runtime,omitempty"`      // container runtime
+	GoVersion   string       `json:"go_version"`             // Go version of the runner
+	Git         *GitInfo     `json:"git,omitempty"`          // revision of this repository
+}
+
+// CPUInfo describes the processor of a host.
+type CPUInfo struct {
+	Model    string `json:"model,omitempty"`
+	Cores    int    `json:"cores"`              // logical cores
+	Governor string `json:"governor,omitempty"` // frequency scaling governor, e.g. "performance"
+}
+
+// RuntimeInfo describes a container runtime and the resources available to
+// containers.
+type RuntimeInfo struct {
+	Name          string `json:"name"` // "docker" or "podman"
+	ClientVersion string `json:"client_version,omitempty"`
+	ServerVersion string `json:"server_version,omitempty"`
+	OS            string `json:"os,omitempty"` // e.g. "Docker Desktop"
+	CPUs          int    `json:"cpus,omitempty"`
+	MemoryBytes   int64  `json:"memory_bytes,omitempty"`
+}
+
+// GitInfo identifies the revision of this repository used to run a benchmark.
+type GitInfo struct {
+	Commit string `json:"commit"`
+	Dirty  bool   `json:"dirty"` // uncommitted changes to tracked files
+}
+
+// String returns the abbreviated commit, marked when there are uncommitted
+// changes.
+func (g GitInfo) String() string {
+	s := g.Commit
+	if len(s) > 12 {
+		s = s[:12]
+	}
+	if g.Dirty {
+		s += " (dirty)"
+	}
+	return s
+}
+
+// CollectEnvironment returns facts about the host, using o to describe the
+// container runtime. Errors are logged and otherwise ignored, such that a
+// benchmark never fails because of missing facts.
+func CollectEnvironment(ctx context.Context, o Orchestrator) Environment {
+	env := Environment{
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		GoVersion: runtime.Version(),
+		CPU: CPUInfo{
+			Cores: runtime.NumCPU(),
+		},
+	}
+	env.Hostname, _ = os.Hostname()
+	if s := osRelease(); s != "" {
+		env.OS = s
+	}
+	env.Kernel = readTrimmed("/proc/sys/kernel/osrelease")
+	env.Computer = strings.TrimSpace(
+		readTrimmed("/sys/devices/virtual/dmi/id/sys_vendor") + " " +
+			readTrimmed("/sys/devices/virtual/dmi/id/product_name"))
+	env.CPU.Model = procField("/proc/cpuinfo", "model name")
+	env.CPU.Governor = readTrimmed("/sys/devices/system/cpu/cpu0/cpufreq/scaling_governor")
+	if s := procField("/proc/meminfo", "MemTotal"); s != "" {
+		// like "32795152 kB"
+		if kb, err := strconv.ParseUint(strings.TrimSuffix(s, " kB"), 10, 64); err == nil {
+			env.MemoryBytes = kb * 1024
+		}
+	}
+	if info, err := o.Info(ctx); err != nil {
+		log.Printf("Could not describe container runtime: %s", err)
+	} else {
+		env.Runtime = &info
+	}
+	if info, err := gitInfo(ctx); err != nil {
+		log.Printf("Could not determine git revision: %s", err)
+	} else {
+		env.Git = &info
+	}
+	return env
+}
+
+// writeEnvironment writes environment.json to the directory path.
+func writeEnvironment(env Environment, path string) error {
+	b, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path, 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(path, "environment.json"), b, 0666)
+}
+
+// readEnvironment reads environment.json from the directory path. It returns
+// nil if the file does not exist, e.g. for results of older versions of the
+// runner.
+func readEnvironment(path string) (*Environment, error) {
+	b, err := os.ReadFile(filepath.Join(path, "environment.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var env Environment
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, fmt.Errorf("environment.json: %w", err)
+	}
+	return &env, nil
+}
+
+// readTrimmed returns the content of the file at path without surrounding
+// white space, or an empty string if the file cannot be read.
+func readTrimmed(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimSpace(b))
+}
+
+// procField returns the value of the first line of a file in "key: value"
+// format, like /proc/cpuinfo, that has the given key.
+func procField(path, key string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		k, v, ok := cut(s.Text(), ":")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// osRelease returns the name of the operating system from /etc/os-release.
+func osRelease() string {
+	f, err := os.Open("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if k, v, ok := cut(s.Text(), "="); ok && k == "PRETTY_NAME" {
+			if u, err := strconv.Unquote(v); err == nil {
+				return u
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+// cut is like strings.Cut, which requires Go 1.18.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// gitInfo returns the revision of the repository in the working directory.
+// Untracked files, like results of earlier benchmarks, do not make it dirty.
+func gitInfo(ctx context.Context) (GitInfo, error) {
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return GitInfo{}, err
+	}
+	info := GitInfo{Commit: string(bytes.TrimSpace(out))}
+	out, err = exec.CommandContext(ctx, "git", "status", "--porcelain", "--untracked-files=no").Output()
+	if err != nil {
+		return GitInfo{}, err
+	}
+	info.Dirty = len(bytes.TrimSpace(out)) > 0
+	return info, nil
+}
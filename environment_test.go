@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	exec "github.com/getsentry/sentry-sdk-benchmark/internal/std/execabs"
+)
+
+func TestProcField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpuinfo")
+	content := "processor\t: 0\nvendor_id\t: GenuineIntel\nmodel name\t: Intel(R) Core(TM) i9-9880H CPU @ 2.30GHz\n\nprocessor\t: 1\nmodel name\t: other\n"
+	if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		Key  string
+		Want string
+	}{
+		{"model name", "Intel(R) Core(TM) i9-9880H CPU @ 2.30GHz"},
+		{"vendor_id", "GenuineIntel"},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		if got := procField(path, tt.Key); got != tt.Want {
+			t.Errorf("procField(%q) = %q, want %q", tt.Key, got, tt.Want)
+		}
+	}
+}
+
+func TestEnvironmentRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if env, err := readEnvironment(dir); env != nil || err != nil {
+		t.Fatalf("readEnvironment of older results = %v, %v, want nil, nil", env, err)
+	}
+	want := Environment{
+		OS:   "linux",
+		Arch: "amd64",
+		CPU:  CPUInfo{Cores: 8},
+		Git:  &GitInfo{Commit: "0123456789abcdef", Dirty: true},
+	}
+	if err := writeEnvironment(want, dir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readEnvironment(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Git.String() != "0123456789ab (dirty)" || got.CPU.Cores != 8 {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestGitInfo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte("{}\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "config.json")
+	git("commit", "-q", "-m", "initial")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	// Results of a benchmark are untracked files.
+	if err := os.MkdirAll(filepath.Join(dir, "result", "1"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "result", "1", "overrides.json"), []byte("{}\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	info, err := gitInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Commit) != 40 || info.Dirty {
+		t.Errorf("gitInfo() = %+v, want a clean commit", info)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"rps": 20}`+"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := gitInfo(context.Background()); err != nil || !info.Dirty {
+		t.Errorf("gitInfo() = %+v, %v, want dirty after changing a tracked file", info, err)
+	}
+}
@@ -23,10 +23,14 @@ import (
 type Plot struct {
 	title     string
 	threshold int
+	rate      bool
 	series    map[string]*labeledSeries
 	label     Labeler
 }
 
+// RateLabel is the label of the offered rate series of an attack.
+const RateLabel = "requests/s"
+
 // An Labeler is a function that returns a label
 // to partition and represent Results in separate (but overlaid) line charts
 // in the rendered plot.
@@ -53,6 +57,7 @@ type labeledSeries struct {
 	buf    map[uint64]point
 	series map[string]*timeSeries
 	label  Labeler
+	counts []float64 // number of requests sent in each second of the attack
 }
 
 // a point to be added to a timeSeries.
@@ -106,12 +111,35 @@ func (ls *labeledSeries) add(r *vegeta.Result) (err error) {
 			return fmt.Errorf("point with sequence number %d in %v", p.seq, err)
 		}
 
+		sec := int(p.t.Sub(ls.began) / time.Second)
+		for len(ls.counts) <= sec {
+			ls.counts = append(ls.counts, 0)
+		}
+		ls.counts[sec]++
+
 		ls.seq++
 	}
 
 	return nil
 }
 
+// rateSeries returns a timeSeries of the number of requests sent per second.
+// The last second is usually incomplete and left out.
+func (ls *labeledSeries) rateSeries() (*timeSeries, error) {
+	var attack string
+	for _, ts := range ls.series {
+		attack = ts.attack
+		break
+	}
+	ts := newTimeSeries(attack, RateLabel)
+	for i := 0; i < len(ls.counts)-1; i++ {
+		if err := ts.add(uint64(i)*1000, ls.counts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ts, nil
+}
+
 // Opt is a functional option type for Plot.
 type Opt func(*Plot)
 
@@ -126,6 +154,12 @@ func Downsample(threshold int) Opt {
 	return func(p *Plot) { p.threshold = threshold }
 }
 
+// OfferedRate returns an Opt that adds a series with the number of requests
+// sent per second to each attack, labeled with RateLabel.
+func OfferedRate() Opt {
+	return func(p *Plot) { p.rate = true }
+}
+
 // Label returns an Opt that sets the given Labeler
 // to be used to partition results into multiple overlaid line charts.
 func Label(l Labeler) Opt {
@@ -180,6 +214,14 @@ func (p *Plot) data() (dataPoints, []string, error) {
 				count += s.len
 			}
 		}
+		if p.rate {
+			s, err := as.rateSeries()
+			if err != nil {
+				return nil, nil, err
+			}
+			series = append(series, s)
+			count += s.len
+		}
 	}
 
 	var (
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	exec "github.com/getsentry/sentry-sdk-benchmark/internal/std/execabs"
+)
+
+// LintResult is the outcome of checking a platform directory.
+type LintResult struct {
+	Path   string    // like "platform/python/django"
+	Issues []string  // problems that must be fixed before running the platform
+	Diffs  []AppDiff // changes of every app compared to the baseline app
+}
+
+// AppDiff summarizes the changes of an app compared to the baseline app, that
+// is the footprint of the instrumentation.
+type AppDiff struct {
+	App   string
+	Files []FileDiff
+}
+
+// FileDiff is a file that differs between an app and the baseline app.
+type FileDiff struct {
+	Path    string // relative to the app directory
+	Status  string // "A" (added), "D" (deleted) or "M" (modified)
+	Added   int    // added lines, -1 for binary files
+	Deleted int    // deleted lines, -1 for binary files
+}
+
+// Lint checks the platform directory at path: the configuration must be valid
+// without unknown keys, there must be a baseline app, every app must have
+// exactly one Dockerfile and instrumented apps must not hardcode a DSN. It also
+// summarizes the changes of every app compared to the baseline app.
+//
+// Lint returns an error only if the checks cannot run. Problems with the
+// platform are reported in the result.
+func Lint(ctx context.Context, path string) (LintResult, error) {
+	path = filepath.Clean(path)
+	r := LintResult{Path: path}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return r, err
+	}
+	if !fi.IsDir() {
+		return r, fmt.Errorf("%s: not a directory", path)
+	}
+	issuef := func(format string, a ...interface{}) {
+		r.Issues = append(r.Issues, fmt.Sprintf(format, a...))
+	}
+
+	if err := lintConfig(filepath.Join(path, "config.json")); err != nil {
+		issuef("%v", err)
+	}
+
+	apps := subDirs(path)
+	hasBaseline := false
+	for _, app := range apps {
+		if app == "baseline" {
+			hasBaseline = true
+		}
+		dockerfiles, err := lintDockerfiles(filepath.Join(path, app))
+		if err != nil {
+			return r, err
+		}
+		switch len(dockerfiles) {
+		case 0:
+			issuef("%s: no Dockerfile", app)
+		case 1:
+		default:
+			issuef("%s: %d Dockerfiles, want exactly one: %s", app, len(dockerfiles), strings.Join(dockerfiles, ", "))
+		}
+		if app == "baseline" {
+			continue
+		}
+		literals, err := findDSNLiterals(filepath.Join(path, app))
+		if err != nil {
+			return r, err
+		}
+		for _, l := range literals {
+			issuef("%s: hardcoded DSN, the runner provides SENTRY_DSN", filepath.Join(app, l))
+		}
+	}
+	if !hasBaseline {
+		issuef("no baseline app")
+		return r, nil
+	}
+
+	for _, app := range apps {
+		if app == "baseline" {
+			continue
+		}
+		files, err := diffApps(ctx, path, "baseline", app)
+		if err != nil {
+			return r, err
+		}
+		r.Diffs = append(r.Diffs, AppDiff{App: app, Files: files})
+	}
+	return r, nil
+}
+
+// lintConfig reads the platform configuration at path, like
+// ReadPlatformConfig, but rejects unknown keys.
+func lintConfig(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var pc PlatformConfig
+	if err := dec.Decode(&pc); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if err := pc.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// lintDockerfiles returns the names of the files of the app directory at path
+// that findDockerfile considers a Dockerfile.
+func lintDockerfiles(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.Contains(strings.ToLower(e.Name()), "dockerfile") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+var (
+	// dsnURLRegex matches DSNs like "https://public@o1.ingest.sentry.io/1".
+	dsnURLRegex = regexp.MustCompile(`\bhttps?://[0-9A-Za-z]+(:[0-9A-Za-z]+)?@[0-9A-Za-z.-]+(:[0-9]+)?/[0-9]+\b`)
+	// dsnOptionRegex matches a DSN option set to a string literal, like
+	// `dsn: "..."` or `ENV SENTRY_DSN=...`.
+	dsnOptionRegex = regexp.MustCompile(`(?i)(\b(sentry_)?dsn\b["']?\s*(=|:|=>)\s*["']|\bENV\s+SENTRY_DSN\b)`)
+)
+
+// findDSNLiterals returns the locations, like "settings.py:12", of DSN literals
+// in the text files in the directory path.
+func findDSNLiterals(path string) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if isBinary(b) {
+			return nil
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		for i, line := range bytes.Split(b, []byte("\n")) {
+			if dsnURLRegex.Match(line) || dsnOptionRegex.Match(line) {
+				found = append(found, rel+":"+strconv.Itoa(i+1))
+			}
+		}
+		return nil
+	})
+	return found, err
+}
+
+// isBinary reports whether b looks like the content of a binary file.
+func isBinary(b []byte) bool {
+	if len(b) > 8000 {
+		b = b[:8000]
+	}
+	return bytes.IndexByte(b, 0) >= 0
+}
+
+// diffApps returns the files that differ between the apps a and b of the
+// platform at path, using git.
+func diffApps(ctx context.Context, path, a, b string) ([]FileDiff, error) {
+	cmd := exec.CommandContext(ctx, "git", "diff", "--no-index", "--numstat", "-z", "--", a, b)
+	cmd.Dir = path
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// differences found
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("git diff: %w", err)
+	}
+	return parseNumstat(out, a, b)
+}
+
+// parseNumstat parses the output of "git diff --no-index --numstat -z a b".
+// Paths in the result are relative to a and b.
+//
+// With -z, every record is "ADDED\tDELETED\tPATH\x00", or
+// "ADDED\tDELETED\t\x00OLD\x00NEW\x00" when the paths differ, as they always do
+// when comparing directories. Added and deleted files have /dev/null as the old
+// or new path.
+func parseNumstat(out []byte, a, b string) ([]FileDiff, error) {
+	fields := strings.Split(string(out), "\x00")
+	var files []FileDiff
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "" {
+			continue
+		}
+		parts := strings.SplitN(fields[i], "\t", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("git diff: unexpected output %q", fields[i])
+		}
+		f := FileDiff{Status: "M", Added: -1, Deleted: -1}
+		if parts[0] != "-" {
+			f.Added, _ = strconv.Atoi(parts[0])
+			f.Deleted, _ = strconv.Atoi(parts[1])
+		}
+		oldPath, newPath := parts[2], parts[2]
+		if parts[2] == "" {
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("git diff: truncated output")
+			}
+			oldPath, newPath = fields[i+1], fields[i+2]
+			i += 2
+		}
+		switch {
+		case oldPath == "/dev/null":
+			f.Status, f.Path = "A", strings.TrimPrefix(newPath, b+"/")
+		case newPath == "/dev/null":
+			f.Status, f.Path = "D", strings.TrimPrefix(oldPath, a+"/")
+		default:
+			f.Path = strings.TrimPrefix(newPath, b+"/")
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// Print writes the issues and the diff summary of r to w.
+func (r LintResult) Print(w io.Writer) {
+	if len(r.Issues) == 0 {
+		fmt.Fprintf(w, "%s: ok\n", r.Path)
+	}
+	for _, issue := range r.Issues {
+		fmt.Fprintf(w, "%s: %s\n", r.Path, issue)
+	}
+	for _, d := range r.Diffs {
+		var added, deleted int
+		for _, f := range d.Files {
+			if f.Added > 0 {
+				added += f.Added
+			}
+			if f.Deleted > 0 {
+				deleted += f.Deleted
+			}
+		}
+		fmt.Fprintf(w, "  %s vs baseline: %d files changed, %d insertions(+), %d deletions(-)\n", d.App, len(d.Files), added, deleted)
+		for _, f := range d.Files {
+			stat := "binary"
+			if f.Added >= 0 {
+				stat = fmt.Sprintf("+%d -%d", f.Added, f.Deleted)
+			}
+			fmt.Fprintf(w, "    %s %s (%s)\n", f.Status, f.Path, stat)
+		}
+	}
+}
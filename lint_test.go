@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	exec "github.com/getsentry/sentry-sdk-benchmark/internal/std/execabs"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	platform := chdirPlatform(t, "baseline", "instrumented")
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(platform, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := Lint(context.Background(), platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Issues) != 0 || len(r.Diffs) != 1 || len(r.Diffs[0].Files) != 0 {
+		t.Fatalf("clean platform: got issues %q, diffs %+v", r.Issues, r.Diffs)
+	}
+
+	write("config.json", `{"target": {"path": "/"}, "rps": 10, "duration": "1s", "warmpu": "1s"}`)
+	write(filepath.Join("instrumented", "app.dockerfile"), "FROM scratch\n")
+	write(filepath.Join("instrumented", "settings.py"), "import sentry_sdk\nsentry_sdk.init(\n    dsn=\"https://public@o1.ingest.sentry.io/1\",\n)\n")
+	r, err = Lint(context.Background(), platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIssues := []string{
+		`config.json: json: unknown field "warmpu"`,
+		"instrumented: 2 Dockerfiles, want exactly one: Dockerfile, app.dockerfile",
+		filepath.Join("instrumented", "settings.py:3") + ": hardcoded DSN, the runner provides SENTRY_DSN",
+	}
+	if diff := cmp.Diff(wantIssues, r.Issues); diff != "" {
+		t.Errorf("issues (-want +got):\n%s", diff)
+	}
+	wantDiffs := []AppDiff{{
+		App: "instrumented",
+		Files: []FileDiff{
+			{Path: "app.dockerfile", Status: "A", Added: 1},
+			{Path: "settings.py", Status: "A", Added: 4},
+		},
+	}}
+	if diff := cmp.Diff(wantDiffs, r.Diffs); diff != "" {
+		t.Errorf("diffs (-want +got):\n%s", diff)
+	}
+
+	if err := os.RemoveAll(filepath.Join(platform, "baseline")); err != nil {
+		t.Fatal(err)
+	}
+	r, err = Lint(context.Background(), platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Issues) == 0 || r.Issues[len(r.Issues)-1] != "no baseline app" {
+		t.Errorf("missing baseline: got issues %q", r.Issues)
+	}
+}
+
+func TestParseNumstat(t *testing.T) {
+	out := "2\t0\t\x00a/Dockerfile\x00b/Dockerfile\x00" +
+		"1\t0\t\x00/dev/null\x00b/sentry.txt\x00" +
+		"0\t4\t\x00a/mysql.json\x00/dev/null\x00" +
+		"-\t-\t\x00a/logo.png\x00b/logo.png\x00"
+	want := []FileDiff{
+		{Path: "Dockerfile", Status: "M", Added: 2},
+		{Path: "sentry.txt", Status: "A", Added: 1},
+		{Path: "mysql.json", Status: "D", Deleted: 4},
+		{Path: "logo.png", Status: "M", Added: -1, Deleted: -1},
+	}
+	got, err := parseNumstat([]byte(out), "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseNumstat (-want +got):\n%s", diff)
+	}
+}
+
+func TestFindDSNLiterals(t *testing.T) {
+	tests := []struct {
+		Line string
+		Want bool
+	}{
+		{`sentry_sdk.init(dsn="https://public@o1.ingest.sentry.io/1")`, true},
+		{`  dsn: 'http://sentry@relay:5000/1'`, true},
+		{`config.dsn = ENV["SENTRY_DSN"]`, false},
+		{`ENV SENTRY_DSN=http://x@y/1`, true},
+		{`"Dsn": "https://public@o1.ingest.sentry.io/1"`, true},
+		{`dsn := os.Getenv("SENTRY_DSN")`, false},
+		{`url = "https://github.com/getsentry/sentry-python"`, false},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		if err := os.WriteFile(filepath.Join(dir, "f"), []byte(tt.Line), 0666); err != nil {
+			t.Fatal(err)
+		}
+		found, err := findDSNLiterals(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(found) > 0; got != tt.Want {
+			t.Errorf("%q: got DSN literal %v, want %v", tt.Line, got, tt.Want)
+		}
+	}
+}
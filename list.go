@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// PlatformInfo describes a platform that can be benchmarked.
+type PlatformInfo struct {
+	Path      string    `json:"path"` // like "platform/python/django"
+	Language  string    `json:"language"`
+	Framework string    `json:"framework"`
+	Apps      []AppInfo `json:"apps"`
+	Variants  []string  `json:"variants,omitempty"` // names of variant runs, see PlatformConfig.Variants
+	Target    string    `json:"target"`             // path of the target, like "/fortunes"
+	RPS       string    `json:"rps"`                // like "10" or "10,50,100"
+	Duration  string    `json:"duration"`
+}
+
+// AppInfo describes an app of a platform.
+type AppInfo struct {
+	Name       string `json:"name"` // like "baseline" or "instrumented"
+	Path       string `json:"path"` // like "platform/python/django/baseline"
+	Dockerfile string `json:"dockerfile,omitempty"`
+}
+
+// ListPlatforms returns all platforms in the directory root, like "platform",
+// in lexical order. Every platform is a directory root/LANGUAGE/FRAMEWORK with
+// a configuration file.
+func ListPlatforms(root string) []PlatformInfo {
+	paths, err := filepath.Glob(filepath.Join(root, "*", "*", "config.json"))
+	if err != nil {
+		panic(err)
+	}
+	var platforms []PlatformInfo
+	for _, path := range paths {
+		pc := MustReadPlatformConfig(path)
+		dir := filepath.Dir(path)
+		p := PlatformInfo{
+			Path:      dir,
+			Language:  filepath.Base(filepath.Dir(dir)),
+			Framework: filepath.Base(dir),
+			Target:    pc.TargetPath(),
+			RPS:       pc.RPS.String(),
+			Duration:  pc.Duration,
+		}
+		for _, name := range subDirs(dir) {
+			app := AppInfo{
+				Name: name,
+				Path: filepath.Join(dir, name),
+			}
+			// A missing Dockerfile is left empty, plan reports it.
+			app.Dockerfile, _ = findDockerfile(app.Path)
+			p.Apps = append(p.Apps, app)
+		}
+		for _, v := range pc.Variants {
+			p.Variants = append(p.Variants, v.Name)
+		}
+		platforms = append(platforms, p)
+	}
+	return platforms
+}
+
+// List writes platforms to w, either as a table with one row per app or, if
+// asJSON is true, as a JSON array.
+func List(w io.Writer, platforms []PlatformInfo, asJSON bool) error {
+	if asJSON {
+		if platforms == nil {
+			platforms = []PlatformInfo{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(platforms)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "LANGUAGE\tFRAMEWORK\tAPP\tDOCKERFILE\tTARGET\tRPS\tDURATION")
+	for _, p := range platforms {
+		apps := append([]AppInfo(nil), p.Apps...)
+		for _, v := range p.Variants {
+			apps = append(apps, AppInfo{Name: v + " (variant)"})
+		}
+		for _, app := range apps {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				p.Language, p.Framework, app.Name, orDash(app.Dockerfile), p.Target, p.RPS, p.Duration)
+		}
+	}
+	return tw.Flush()
+}
+
+// orDash returns s, or "-" if s is empty.
+func orDash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListPlatforms(t *testing.T) {
+	chdirPlatform(t, "baseline", "instrumented")
+	dir := filepath.Join("platform", "python", "django")
+	want := []PlatformInfo{
+		{
+			Path:      dir,
+			Language:  "python",
+			Framework: "django",
+			Apps: []AppInfo{
+				{Name: "baseline", Path: filepath.Join(dir, "baseline"), Dockerfile: "Dockerfile"},
+				{Name: "instrumented", Path: filepath.Join(dir, "instrumented"), Dockerfile: "Dockerfile"},
+			},
+			Target:   "/",
+			RPS:      "10",
+			Duration: "1s",
+		},
+	}
+	got := ListPlatforms("platform")
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("ListPlatforms (-want +got):\n%s", diff)
+	}
+
+	var b bytes.Buffer
+	if err := List(&b, got, true); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []PlatformInfo
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, decoded); diff != "" {
+		t.Errorf("List JSON (-want +got):\n%s", diff)
+	}
+
+	b.Reset()
+	if err := List(&b, got, false); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); len(lines) != 3 {
+		t.Errorf("List table: got %d lines, want header and 2 apps:\n%s", len(lines), b.String())
+	}
+
+	b.Reset()
+	if err := List(&b, nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(b.String()); got != "[]" {
+		t.Errorf("List JSON of no platforms = %q, want %q", got, "[]")
+	}
+}
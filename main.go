@@ -2,14 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
 var usage = `
@@ -20,6 +23,87 @@ Benchmark one or more platforms.
 Examples:
 %[1]s platform/python/django
 %[1]s run platform/javascript/express
+%[1]s -count 3 platform/python/django
+%[1]s -rps 10-100/10 platform/python/django
+%[1]s -duration 2m -warmup 30s -target /fortunes platform/python/django
+%[1]s -orchestrator podman platform/python/django
+%[1]s -keep platform/python/django/instrumented
+%[1]s -timeout 2h -run-timeout 10m platform/python/*
+
+Usage:	%[1]s plan PLATFORM [PLATFORM ...]
+
+Print the execution plan of a benchmark without running anything: the runs in
+order, with their Docker Compose project names, result paths and load generator
+command lines, and an estimate of the total run time.
+Same as "%[1]s run -n PLATFORM ...".
+
+Examples:
+%[1]s plan platform/python/django
+%[1]s -count 5 -rps 10-100/10 plan platform/python/*
+
+Usage:	%[1]s list [-json]
+
+List the platforms that can be benchmarked, with their apps, Dockerfiles,
+target path, request rates and test duration. With -json, print a JSON array of
+platforms, for example to build CI job matrices.
+
+Examples:
+%[1]s list
+%[1]s list -json | jq -r '.[].apps[].path'
+
+Usage:	%[1]s new TFB LANGUAGE FRAMEWORK
+
+Create a new platform from a framework of a local checkout of the TechEmpower
+Framework Benchmarks at TFB. Copies the framework to
+platform/LANGUAGE/FRAMEWORK/baseline, keeping only the Dockerfile of the
+Postgres test, generates config.json from benchmark_config.json and copies the
+baseline app to instrumented. See platform/README.md for the next steps.
+
+Examples:
+%[1]s new ../FrameworkBenchmarks Python flask
+
+Usage:	%[1]s lint [PLATFORM ...]
+
+Check platform directories for common mistakes before running them: invalid
+configuration or unknown configuration keys, missing baseline app, apps
+without exactly one Dockerfile and hardcoded DSNs. Prints a summary of the
+changes of every app compared to the baseline app. Without arguments, checks
+all platforms.
+
+Examples:
+%[1]s lint
+%[1]s lint platform/python/django
+
+Usage:	%[1]s cache [ls | prune]
+
+List or remove the images cached by previous benchmark runs. Images are tagged
+with a hash of their build context and reused by later runs with the same build
+context, unless running with -rebuild.
+
+Examples:
+%[1]s cache
+%[1]s -orchestrator podman cache prune
+
+Usage:	%[1]s down ID|RESULT [ID|RESULT ...]
+
+Tear down the containers left running by a benchmark run with -keep. With
+-keep, the containers of every run keep running after the load generator exits
+and the ports of the app, the relay and cAdvisor are published on localhost,
+for debugging.
+
+Examples:
+%[1]s down tbnfsga
+%[1]s down result/python/django/20210818-082527-tbnfsga
+
+Usage:	%[1]s rerun RESULT
+
+Repeat a benchmark with the inputs recorded in the manifest.json files of its
+result directory: the same platform configuration, including command line
+overrides, apps, variants and repetitions. Apps are built from the platform
+directory in the working tree, with a warning if their build context changed.
+
+Examples:
+%[1]s rerun result/python/django/20210818-082527-tbnfsga
 
 Usage:	%[1]s report RESULT [RESULT ...]
 
@@ -56,6 +140,52 @@ var openBrowser = true
 // as expected and that instrumented apps do what they need to do.
 var sanityCheckMode bool
 
+// count is the number of times to run each app. Repetitions are interleaved,
+// such that the order of the apps changes from one repetition to the next.
+var count int
+
+// rebuild forces building images even if they are cached.
+var rebuild bool
+
+// keep leaves the containers of every run running for debugging.
+var keep bool
+
+// timeout limits the time to run all benchmarks of a command line execution,
+// and runTimeout the time of a single run after building its images. Runs
+// that time out are stopped early, keeping partial results.
+var timeout, runTimeout time.Duration
+
+// deadline is the time at which timeout expires.
+var deadline time.Time
+
+// statsSource is where loadgen reads container stats, one of StatsSources.
+var statsSource string
+
+// dryRun prints the execution plan of benchmarks instead of running them.
+var dryRun bool
+
+// orchestrator is the name of the container orchestrator used to run apps.
+var orchestrator string
+
+// overrides holds values of the flags that override the platform
+// configuration, keyed by flag name. Multiple rates passed to -rps run each app
+// at every rate (a sweep).
+var overrides = make(map[string]string)
+
+// overrideFlag defines a flag that overrides a field of the platform
+// configuration.
+func overrideFlag(name, usage string) {
+	flag.Func(name, usage, func(s string) error {
+		// Report invalid values early, while parsing flags.
+		var cfg BenchmarkConfig
+		if err := cfg.Override(name, s); err != nil {
+			return err
+		}
+		overrides[name] = s
+		return nil
+	})
+}
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
 	log.SetPrefix("[sentry-sdk-benchmark] ")
@@ -75,8 +205,38 @@ func main() {
 
 	flag.BoolVar(&openBrowser, "browser", true, "open report in browser")
 	flag.BoolVar(&sanityCheckMode, "s", false, "sanity check mode (for project maintainers)")
+	flag.IntVar(&count, "count", 1, "run each app `n` times")
+	flag.BoolVar(&rebuild, "rebuild", false, "build images even if cached images match their build context")
+	flag.BoolVar(&keep, "keep", false, "leave containers running after each run and publish their ports, see the down subcommand")
+	flag.DurationVar(&timeout, "timeout", 0, "stop all benchmarks after `duration`, keeping partial results (0 for no limit)")
+	flag.DurationVar(&runTimeout, "run-timeout", 0, "stop each run after `duration`, keeping partial results (0 for no limit)")
+	flag.StringVar(&statsSource, "stats", "cadvisor", "`source` of container stats, one of: "+strings.Join(StatsSources, ", "))
+	flag.BoolVar(&dryRun, "n", false, "print the execution plan without running anything (same as the plan subcommand)")
+	flag.StringVar(&orchestrator, "orchestrator", "docker", "container orchestrator used to run apps, one of: "+strings.Join(Orchestrators, ", "))
+	overrideFlag("rps", "override requests per second with `rates` like 10 or 10,50,100 or 10-100/10")
+	overrideFlag("duration", "override test `duration`")
+	overrideFlag("warmup", "override warmup `duration`")
+	overrideFlag("maxwait", "override max wait `duration` until the app is ready")
+	overrideFlag("target", "override target `path`, like /fortunes")
 
 	flag.Parse()
+	if count < 1 {
+		fmt.Fprintln(os.Stderr, "flag -count must be positive")
+		os.Exit(2)
+	}
+	if !contains(StatsSources, statsSource) {
+		fmt.Fprintf(os.Stderr, "flag -stats must be one of: %s\n", strings.Join(StatsSources, ", "))
+		os.Exit(2)
+	}
+	if orchestrator == "podman" && !usesCAdvisor(statsSource) {
+		// loadgen reads stats from the Docker socket, which Podman
+		// does not provide at the same path.
+		fmt.Fprintf(os.Stderr, "flag -stats %s cannot be used with -orchestrator podman\n", statsSource)
+		os.Exit(2)
+	}
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
 	if len(flag.Args()) < 1 {
 		printUsage()
 		os.Exit(2)
@@ -100,24 +260,270 @@ func main() {
 			os.Exit(2)
 		}
 		Compare(args)
-	case "run":
+	case "cache":
+		args = args[1:]
+		prune := len(args) == 1 && args[0] == "prune"
+		if len(args) > 1 || (len(args) == 1 && !prune && args[0] != "ls") {
+			printUsage()
+			os.Exit(2)
+		}
+		o, err := NewOrchestrator(orchestrator)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if err := Cache(ctx, o, os.Stdout, prune); err != nil {
+			panic(err)
+		}
+	case "down":
+		args = args[1:]
+		if len(args) == 0 {
+			printUsage()
+			os.Exit(2)
+		}
+		o, err := NewOrchestrator(orchestrator)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if err := Down(o, os.Stdout, args); err != nil {
+			panic(err)
+		}
+	case "list":
+		args = args[1:]
+		asJSON := len(args) == 1 && args[0] == "-json"
+		if len(args) > 1 || (len(args) == 1 && !asJSON) {
+			printUsage()
+			os.Exit(2)
+		}
+		if err := List(os.Stdout, ListPlatforms("platform"), asJSON); err != nil {
+			panic(err)
+		}
+	case "new":
 		args = args[1:]
+		if len(args) != 3 {
+			printUsage()
+			os.Exit(2)
+		}
+		if _, err := Scaffold(os.Stdout, args[0], args[1], args[2]); err != nil {
+			panic(err)
+		}
+	case "rerun":
+		args = args[1:]
+		if len(args) != 1 {
+			printUsage()
+			os.Exit(2)
+		}
+		o, err := NewOrchestrator(orchestrator)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		bc, err := BenchmarkConfigFromResult(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		// The stats source changes what is measured, so it is
+		// restored from the manifest rather than taken from -stats.
+		recorded := bc.StatsSource
+		if usesCAdvisor(recorded) {
+			recorded = "cadvisor"
+		}
+		if isFlagPassed("stats") && statsSource != recorded {
+			fmt.Fprintf(os.Stderr, "flag -stats %s conflicts with stats source %s of the recorded benchmark\n", statsSource, recorded)
+			os.Exit(2)
+		}
+		if orchestrator == "podman" && !usesCAdvisor(recorded) {
+			fmt.Fprintf(os.Stderr, "recorded stats source %s cannot be used with -orchestrator podman\n", recorded)
+			os.Exit(2)
+		}
+		applyRunFlags(&bc)
+		bc.StatsSource = recorded
+		results, err := Benchmark(ctx, o, bc)
+		if ok := printSummary(os.Stderr, summaryRows(args[0], results, err)); !ok {
+			os.Exit(1)
+		}
+	case "lint":
+		args = args[1:]
+		if len(args) == 0 {
+			paths, err := filepath.Glob(filepath.Join("platform", "*", "*", "config.json"))
+			if err != nil {
+				panic(err)
+			}
+			for _, p := range paths {
+				args = append(args, filepath.Dir(p))
+			}
+		}
+		ok := true
+		for _, path := range args {
+			r, err := Lint(ctx, path)
+			if err != nil {
+				panic(err)
+			}
+			r.Print(os.Stdout)
+			if len(r.Issues) > 0 {
+				ok = false
+			}
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	case "plan", "run":
+		if args[0] == "plan" {
+			dryRun = true
+		}
+		args = args[1:]
+		if len(args) > 0 && args[0] == "-n" {
+			dryRun = true
+			args = args[1:]
+		}
 		fallthrough
 	default:
 		if len(args) == 0 {
 			printUsage()
 			os.Exit(2)
 		}
+		if dryRun {
+			if ok := plan(os.Stdout, args); !ok {
+				os.Exit(1)
+			}
+			return
+		}
 		if len(args) > 1 {
 			openBrowser = false
 		}
+		o, err := NewOrchestrator(orchestrator)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		var rows []summaryRow
 		for _, path := range args {
-			bc := BenchmarkConfigFromPath(path)
-			if sanityCheckMode {
-				bc.PlatformConfig.RPS = 3
-				bc.PlatformConfig.Duration = "5s"
+			if ctx.Err() != nil {
+				rows = append(rows, summaryRow{Platform: path, Status: StatusCanceled, Err: ctx.Err()})
+				continue
+			}
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				rows = append(rows, summaryRow{Platform: path, Status: StatusTimedOut, Err: errors.New("session timeout exceeded")})
+				continue
 			}
-			Benchmark(ctx, bc)
+			rows = append(rows, benchmark(ctx, o, path)...)
+		}
+		if ok := printSummary(os.Stderr, rows); !ok {
+			os.Exit(1)
+		}
+	}
+}
+
+// benchmark runs the benchmark of the platform or app at path with orchestrator
+// o and returns one summary row per run. Errors that prevent the benchmark from
+// running at all are reported in a row of their own.
+func benchmark(ctx context.Context, o Orchestrator, path string) []summaryRow {
+	bc, err := benchmarkConfig(path)
+	if err != nil {
+		log.Printf("Cannot benchmark %q: %s", path, err)
+		return []summaryRow{{Platform: path, Status: statusOf(err), Err: err}}
+	}
+	results, err := Benchmark(ctx, o, bc)
+	return summaryRows(path, results, err)
+}
+
+// summaryRows returns one summary row per run of a benchmark of the platform or
+// app at path, and one for err, if not nil.
+func summaryRows(path string, results []*RunResult, err error) []summaryRow {
+	var rows []summaryRow
+	for _, res := range results {
+		rows = append(rows, summaryRow{
+			Platform: path,
+			Run:      res.Label(),
+			Status:   statusOf(res.Err),
+			Err:      res.Err,
+		})
+	}
+	if err != nil {
+		rows = append(rows, summaryRow{Platform: path, Status: statusOf(err), Err: err})
+	}
+	return rows
+}
+
+// plan writes the execution plan of the benchmarks of all paths to w and
+// reports whether all of them can run.
+func plan(w io.Writer, paths []string) bool {
+	ok := true
+	var total time.Duration
+	for i, path := range paths {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		bc, err := benchmarkConfig(path)
+		if err != nil {
+			fmt.Fprintf(w, "%s: error: %s\n", path, err)
+			ok = false
+			continue
+		}
+		if err := Plan(w, bc); err != nil {
+			fmt.Fprintf(w, "%s: error: %s\n", path, err)
+			ok = false
+		}
+		total += time.Duration(len(bc.Schedule())) * bc.PlatformConfig.EstimatedRunTime()
+	}
+	if len(paths) > 1 {
+		fmt.Fprintf(w, "\nTotal estimated time: %v\n", total)
+	}
+	return ok
+}
+
+// applyRunFlags applies the command line flags that control how runs are
+// executed, but not what they measure, to bc.
+func applyRunFlags(bc *BenchmarkConfig) {
+	bc.Rebuild = rebuild
+	bc.Keep = keep
+	bc.RunTimeout = runTimeout
+	bc.Deadline = deadline
+}
+
+// isFlagPassed reports whether the flag with the given name was set on the
+// command line.
+func isFlagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// benchmarkConfig returns the configuration of the benchmark of the platform or
+// app at path, with the command line flags applied.
+func benchmarkConfig(path string) (BenchmarkConfig, error) {
+	bc, err := BenchmarkConfigFromPath(path)
+	if err != nil {
+		return BenchmarkConfig{}, err
+	}
+	bc.Count = count
+	applyRunFlags(&bc)
+	bc.StatsSource = statsSource
+	if sanityCheckMode {
+		bc.PlatformConfig.RPS = Rates{3}
+		bc.PlatformConfig.Duration = "5s"
+		bc.PlatformConfig.Warmup = "0s"
+		bc.PlatformConfig.Profile = nil
+	}
+	if len(overrides) > 0 {
+		// Apply in a fixed order, such that errors are reported
+		// consistently.
+		for _, name := range OverrideFlags {
+			if value, ok := overrides[name]; ok {
+				if err := bc.Override(name, value); err != nil {
+					return BenchmarkConfig{}, err
+				}
+			}
+		}
+		if err := bc.PlatformConfig.Validate(); err != nil {
+			return BenchmarkConfig{}, err
 		}
 	}
+	return bc, nil
 }
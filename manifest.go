@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Manifest records the inputs of a run, such that the benchmark can be
+// repeated with the rerun subcommand. It is written to manifest.json in the
+// result directory of every run, next to the rendered docker-compose.yml.
+type Manifest struct {
+	// Inputs of the benchmark, the same for all of its runs.
+	Platform       string            `json:"platform"`               // like "platform/python/django"
+	PlatformConfig PlatformConfig    `json:"platform_config"`        // with command line overrides applied
+	Overrides      map[string]string `json:"overrides,omitempty"`    // command line overrides, by flag name
+	StatsSource    string            `json:"stats_source,omitempty"` // one of StatsSources, empty for cAdvisor
+	Runs           []RunConfig       `json:"runs"`
+	Count          int               `json:"count"`
+
+	// Inputs of this run.
+	Run    RunConfig                `json:"run"`
+	Images map[string]ManifestImage `json:"images"`        // by service name
+	SDK    *SDKInfo                 `json:"sdk,omitempty"` // as reported by the app to the relay
+}
+
+// ManifestImage identifies the image of a service.
+type ManifestImage struct {
+	Ref         string `json:"ref"`                    // like "sentry-sdk-benchmark/loadgen:0123456789ab"
+	ID          string `json:"id,omitempty"`           // local image ID
+	Digest      string `json:"digest,omitempty"`       // repository digest of pulled images
+	ContextHash string `json:"context_hash,omitempty"` // hash of the build context of built images
+}
+
+// newManifest returns the manifest of a run, resolving image IDs and digests
+// with o. Images that cannot be resolved are recorded by reference only.
+func newManifest(ctx context.Context, o Orchestrator, benchmarkCfg BenchmarkConfig, runCfg RunConfig, images Images) Manifest {
+	m := Manifest{
+		Platform:       benchmarkCfg.Platform,
+		PlatformConfig: benchmarkCfg.PlatformConfig,
+		Overrides:      benchmarkCfg.Overrides,
+		StatsSource:    benchmarkCfg.StatsSource,
+		Runs:           benchmarkCfg.Runs,
+		Count:          benchmarkCfg.Count,
+		Run:            runCfg,
+		Images:         make(map[string]ManifestImage),
+	}
+	services := map[string]string{
+		"app":          images.App,
+		"loadgen":      images.LoadGen,
+		"tfb-database": images.Database,
+		"cadvisor":     images.CAdvisor,
+	}
+	if runCfg.NeedsRelay {
+		services["relay"] = images.Relay
+	}
+	for service, ref := range services {
+		if ref == "" {
+			// not used by this run, like cAdvisor with another
+			// stats source
+			continue
+		}
+		mi := ManifestImage{Ref: ref}
+		if service != "cadvisor" {
+			// Built images are tagged with the hash of their
+			// build context, see planImages.
+			mi.ContextHash = ref[strings.LastIndex(ref, ":")+1:]
+		}
+		img, err := o.InspectImage(ctx, ref)
+		if err != nil {
+			log.Printf("Could not inspect image %s: %s", ref, err)
+		} else {
+			mi.ID, mi.Digest = img.ID, img.Digest
+		}
+		m.Images[service] = mi
+	}
+	return m
+}
+
+// writeManifest writes manifest.json to the result directory of r. The SDK
+// information is taken from the result of the run, if any.
+func writeManifest(m Manifest, r *RunResult) error {
+	if r.hasResult() {
+		if tr, err := readTestResult(filepath.Join(r.Path, "result.json")); err == nil && tr.RelayMetrics.SDKInfo.Name != "" {
+			m.SDK = &tr.RelayMetrics.SDKInfo
+		}
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.Path, "manifest.json"), b, 0666)
+}
+
+// readManifests returns the manifests of all runs in the result directory path,
+// in lexical order of their paths.
+func readManifests(path string) ([]Manifest, error) {
+	var manifests []Manifest
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "manifest.json" {
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		var m Manifest
+		if err := json.Unmarshal(b, &m); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		manifests = append(manifests, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no manifest.json in %s", path)
+	}
+	return manifests, nil
+}
+
+// BenchmarkConfigFromResult returns the configuration of a benchmark that
+// repeats the benchmark with results in path, as recorded in its manifests.
+// The new benchmark has a new ID and start time, and reads container stats
+// from the same source.
+//
+// The apps are built from the platform directory in the working tree. A
+// warning is logged for every app whose build context changed since the
+// recorded run, such that the new images differ from the recorded ones.
+func BenchmarkConfigFromResult(path string) (BenchmarkConfig, error) {
+	manifests, err := readManifests(path)
+	if err != nil {
+		return BenchmarkConfig{}, err
+	}
+	m := manifests[0]
+	if err := m.PlatformConfig.Validate(); err != nil {
+		return BenchmarkConfig{}, fmt.Errorf("manifest: %w", err)
+	}
+	cfg := BenchmarkConfig{
+		ID:             NewBenchmarkID(),
+		StartTime:      time.Now().UTC(),
+		Platform:       m.Platform,
+		PlatformConfig: m.PlatformConfig,
+		Overrides:      m.Overrides,
+		StatsSource:    m.StatsSource,
+		Runs:           m.Runs,
+		Count:          m.Count,
+	}
+	if len(cfg.Runs) == 0 {
+		return BenchmarkConfig{}, fmt.Errorf("manifest: no runs")
+	}
+	for _, m := range manifests {
+		plan, err := planRun(cfg, m.Run)
+		if err != nil {
+			return BenchmarkConfig{}, err
+		}
+		if recorded := m.Images["app"].Ref; plan.Images.App != recorded {
+			log.Printf("Warning: build context of %s changed, image %s differs from recorded image %s", m.Run.Name, plan.Images.App, recorded)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBenchmarkConfigFromResult(t *testing.T) {
+	defer func(b bool) { openBrowser = b }(openBrowser)
+	openBrowser = false
+
+	platform := chdirPlatform(t, "baseline", "instrumented")
+	cfg, err := BenchmarkConfigFromPath(platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Count = 2
+	cfg.StatsSource = "cadvisor"
+	if err := cfg.Override("duration", "2s"); err != nil {
+		t.Fatal(err)
+	}
+	o := &fakeOrchestrator{Files: fakeResultFiles(t, 20)}
+	results, err := Benchmark(context.Background(), o, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(results[0].Path, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range []string{"app", "loadgen", "tfb-database"} {
+		img := m.Images[service]
+		if img.ID == "" || img.ContextHash == "" {
+			t.Errorf("%s: image not resolved: %+v", service, img)
+		}
+	}
+	if _, ok := m.Images["relay"]; ok != m.Run.NeedsRelay {
+		t.Errorf("%s: got relay image %v, want %v", m.Run.Name, ok, m.Run.NeedsRelay)
+	}
+	if got := m.Images["cadvisor"].Ref; got != cadvisorImage {
+		t.Errorf("got cAdvisor image %q, want %q", got, cadvisorImage)
+	}
+
+	rerun, err := BenchmarkConfigFromResult(cfg.ResultPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rerun.ID == cfg.ID {
+		t.Error("rerun has the same ID as the original benchmark")
+	}
+	if diff := cmp.Diff(cfg.Schedule(), rerun.Schedule()); diff != "" {
+		t.Errorf("schedule (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(cfg.PlatformConfig, rerun.PlatformConfig); diff != "" {
+		t.Errorf("platform config (-want +got):\n%s", diff)
+	}
+	if rerun.Platform != cfg.Platform {
+		t.Errorf("got platform %q, want %q", rerun.Platform, cfg.Platform)
+	}
+	// Overrides are restored, such that the report of the rerun warns
+	// about them like the report of the original benchmark.
+	if diff := cmp.Diff(map[string]string{"duration": "2s"}, rerun.Overrides); diff != "" {
+		t.Errorf("overrides (-want +got):\n%s", diff)
+	}
+
+	if rerun.StatsSource != cfg.StatsSource {
+		t.Errorf("got stats source %q, want %q", rerun.StatsSource, cfg.StatsSource)
+	}
+
+	if _, err := BenchmarkConfigFromResult(t.TempDir()); err == nil {
+		t.Error("result without manifests: got nil error")
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	exec "github.com/getsentry/sentry-sdk-benchmark/internal/std/execabs"
+)
+
+// Orchestrator builds and runs the containers of a benchmark run, as described
+// by a Docker Compose file.
+type Orchestrator interface {
+	// Build builds the images of a project.
+	Build(ctx context.Context, p Project) error
+	// Up starts the containers of a project and waits until the load
+	// generator exits, streaming the output of all containers to w.
+	Up(ctx context.Context, p Project, w io.Writer) error
+	// Logs writes the output of all containers of a project to w.
+	Logs(ctx context.Context, p Project, w io.Writer) error
+	// Port returns the host address, like "127.0.0.1:49153", to which a
+	// port of a service is published. Ports are only published when the
+	// project is kept.
+	Port(ctx context.Context, p Project, service string, port int) (string, error)
+	// Stop sends SIGTERM to the containers of a service of a project and
+	// waits until they exit, killing them after timeout.
+	Stop(ctx context.Context, p Project, service string, timeout time.Duration) error
+	// Down stops and removes the containers of a project.
+	Down(p Project) error
+	// Info describes the container runtime.
+	Info(ctx context.Context) (RuntimeInfo, error)
+
+	// ImageExists reports whether an image exists locally.
+	ImageExists(ctx context.Context, ref string) (bool, error)
+	// InspectImage returns the ID and digest of a local image.
+	InspectImage(ctx context.Context, ref string) (Image, error)
+	// Images lists the images built by the runner.
+	Images(ctx context.Context) ([]Image, error)
+	// RemoveImages removes images.
+	RemoveImages(ctx context.Context, refs []string) error
+}
+
+// Project is the set of containers of a benchmark run.
+type Project struct {
+	Name        string // unique name, like "python-django-baseline-tbnfsga"
+	ComposeFile []byte
+	ResultPath  string   // directory where the load generator writes its results
+	Images      []string // images used by the project
+
+	// Keep leaves the containers running after the load generator exits,
+	// with ports published to the host for debugging.
+	Keep             bool
+	LoadGenContainer string // name of the load generator container
+}
+
+// Orchestrators lists the names accepted by NewOrchestrator.
+var Orchestrators = []string{"docker", "podman"}
+
+// NewOrchestrator returns the orchestrator with the given name.
+func NewOrchestrator(name string) (Orchestrator, error) {
+	switch name {
+	case "docker":
+		return composeOrchestrator{
+			runtime:  "docker",
+			command:  []string{"docker", "compose"},
+			stdin:    true,
+			downArgs: []string{"--remove-orphans"},
+		}, nil
+	case "podman":
+		return composeOrchestrator{
+			runtime: "podman",
+			command: []string{"podman-compose"},
+			// podman-compose cannot read the compose file from standard
+			// input.
+			stdin: false,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown orchestrator %q, must be one of %s", name, strings.Join(Orchestrators, ", "))
+}
+
+// loadgenExitNotReady is the exit code of loadgen when the target app does not
+// become ready to receive traffic.
+//
+// Copied from ./tool/loadgen.
+const loadgenExitNotReady = 3
+
+// composeOrchestrator runs projects with Docker Compose or a tool with a
+// compatible command line, like podman-compose.
+type composeOrchestrator struct {
+	runtime  string   // container runtime, "docker" or "podman"
+	command  []string // like "docker compose"
+	stdin    bool     // whether the compose file can be read from standard input
+	downArgs []string // extra arguments to the down command; images are kept for reuse
+}
+
+func (o composeOrchestrator) Build(ctx context.Context, p Project) error {
+	return o.run(ctx, p, os.Stdout, os.Stderr, "build")
+}
+
+func (o composeOrchestrator) Up(ctx context.Context, p Project, w io.Writer) error {
+	if p.Keep {
+		return o.upKeep(ctx, p, w)
+	}
+	err := o.run(ctx, p, w, w, "up", "--exit-code-from", "loadgen")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == loadgenExitNotReady {
+		return runError(StatusNotReady, err)
+	}
+	return err
+}
+
+// upKeep is like Up, but leaves the other containers running after the load
+// generator exits. Up with --exit-code-from would stop all containers.
+func (o composeOrchestrator) upKeep(ctx context.Context, p Project, w io.Writer) error {
+	if err := o.run(ctx, p, w, w, "up", "--detach"); err != nil {
+		return err
+	}
+
+	logsCtx, stopLogs := context.WithCancel(ctx)
+	defer stopLogs()
+	logs, cleanup, err := o.cmd(logsCtx, p, "logs", "--follow", "--no-color")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	logs.Stdout = w
+	logs.Stderr = w
+	if err := logs.Start(); err != nil {
+		return err
+	}
+	defer func() {
+		stopLogs()
+		_ = logs.Wait()
+	}()
+
+	out, err := exec.CommandContext(ctx, o.runtime, "wait", p.LoadGenContainer).Output()
+	if err != nil {
+		return fmt.Errorf("%s wait: %w", o.runtime, err)
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return fmt.Errorf("%s wait: unexpected output %q", o.runtime, out)
+	}
+	switch code {
+	case 0:
+		return nil
+	case loadgenExitNotReady:
+		return runError(StatusNotReady, fmt.Errorf("loadgen exit status %d", code))
+	default:
+		return fmt.Errorf("loadgen exit status %d", code)
+	}
+}
+
+func (o composeOrchestrator) Port(ctx context.Context, p Project, service string, port int) (string, error) {
+	cmd, cleanup, err := o.cmd(ctx, p, "port", service, strconv.Itoa(port))
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("port %s %d: %w", service, port, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (o composeOrchestrator) Logs(ctx context.Context, p Project, w io.Writer) error {
+	return o.run(ctx, p, w, w, "logs", "--no-color")
+}
+
+func (o composeOrchestrator) Stop(ctx context.Context, p Project, service string, timeout time.Duration) error {
+	return o.run(ctx, p, os.Stdout, os.Stderr, "stop", "--timeout", strconv.Itoa(int(timeout.Seconds())), service)
+}
+
+func (o composeOrchestrator) Down(p Project) error {
+	return o.run(context.Background(), p, os.Stdout, os.Stderr, append([]string{"down"}, o.downArgs...)...)
+}
+
+func (o composeOrchestrator) Info(ctx context.Context) (RuntimeInfo, error) {
+	info := RuntimeInfo{Name: o.runtime}
+	switch o.runtime {
+	case "docker":
+		var v struct {
+			ServerVersion   string
+			OperatingSystem string
+			NCPU            int
+			MemTotal        int64
+		}
+		if err := runJSON(ctx, &v, "docker", "info", "--format", "{{json .}}"); err != nil {
+			return info, err
+		}
+		info.ServerVersion = v.ServerVersion
+		info.OS = v.OperatingSystem
+		info.CPUs = v.NCPU
+		info.MemoryBytes = v.MemTotal
+		var c struct{ Version string }
+		if err := runJSON(ctx, &c, "docker", "version", "--format", "{{json .Client}}"); err != nil {
+			return info, err
+		}
+		info.ClientVersion = c.Version
+	case "podman":
+		var v struct {
+			Host struct {
+				CPUs         int   `json:"cpus"`
+				MemTotal     int64 `json:"memTotal"`
+				Distribution struct {
+					Distribution string `json:"distribution"`
+					Version      string `json:"version"`
+				} `json:"distribution"`
+			} `json:"host"`
+			Version struct {
+				Version string `json:"Version"`
+			} `json:"version"`
+		}
+		if err := runJSON(ctx, &v, "podman", "info", "--format", "json"); err != nil {
+			return info, err
+		}
+		info.ClientVersion = v.Version.Version
+		info.ServerVersion = v.Version.Version
+		info.OS = strings.TrimSpace(v.Host.Distribution.Distribution + " " + v.Host.Distribution.Version)
+		info.CPUs = v.Host.CPUs
+		info.MemoryBytes = v.Host.MemTotal
+	}
+	return info, nil
+}
+
+func (o composeOrchestrator) ImageExists(ctx context.Context, ref string) (bool, error) {
+	err := exec.CommandContext(ctx, o.runtime, "image", "inspect", "--format", "{{.Id}}", ref).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// no such image
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (o composeOrchestrator) InspectImage(ctx context.Context, ref string) (Image, error) {
+	// Docker and Podman both print a JSON array of images.
+	var v []struct {
+		ID          string   `json:"Id"`
+		RepoDigests []string `json:"RepoDigests"`
+	}
+	if err := runJSON(ctx, &v, o.runtime, "image", "inspect", ref); err != nil {
+		return Image{}, err
+	}
+	if len(v) != 1 {
+		return Image{}, fmt.Errorf("%s image inspect %s: got %d images", o.runtime, ref, len(v))
+	}
+	img := Image{Ref: ref, ID: v[0].ID}
+	if len(v[0].RepoDigests) > 0 {
+		img.Digest = v[0].RepoDigests[0]
+	}
+	return img, nil
+}
+
+func (o composeOrchestrator) Images(ctx context.Context) ([]Image, error) {
+	filter := "label=" + imageLabel
+	var images []Image
+	switch o.runtime {
+	case "docker":
+		// one JSON object per line
+		out, err := exec.CommandContext(ctx, "docker", "image", "ls", "--filter", filter, "--format", "{{json .}}").Output()
+		if err != nil {
+			return nil, fmt.Errorf("docker image ls: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(out))
+		for dec.More() {
+			var v struct {
+				Repository, Tag, ID, CreatedSince, Size string
+			}
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			images = append(images, Image{
+				Ref:     v.Repository + ":" + v.Tag,
+				ID:      v.ID,
+				Created: v.CreatedSince,
+				Size:    v.Size,
+			})
+		}
+	case "podman":
+		var v []struct {
+			ID      string   `json:"Id"`
+			Names   []string `json:"Names"`
+			Created int64    `json:"Created"`
+			Size    int64    `json:"Size"`
+		}
+		if err := runJSON(ctx, &v, "podman", "images", "--filter", filter, "--format", "json"); err != nil {
+			return nil, err
+		}
+		for _, img := range v {
+			id := img.ID
+			if len(id) > 12 {
+				id = id[:12]
+			}
+			for _, name := range img.Names {
+				images = append(images, Image{
+					Ref:     name,
+					ID:      id,
+					Created: time.Unix(img.Created, 0).Format(time.RFC3339),
+					Size:    byteCountSI(img.Size),
+				})
+			}
+		}
+	}
+	return images, nil
+}
+
+func (o composeOrchestrator) RemoveImages(ctx context.Context, refs []string) error {
+	cmd := exec.CommandContext(ctx, o.runtime, append([]string{"image", "rm"}, refs...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runJSON runs a command and decodes its JSON output into v.
+func runJSON(ctx context.Context, v interface{}, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", name, args[0], err)
+	}
+	return json.Unmarshal(out, v)
+}
+
+// run runs a subcommand of the compose tool for project p.
+func (o composeOrchestrator) run(ctx context.Context, p Project, stdout, stderr io.Writer, args ...string) error {
+	cmd, cleanup, err := o.cmd(ctx, p, args...)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// cmd returns a command that runs a subcommand of the compose tool for
+// project p. The caller must call cleanup after the command exits.
+func (o composeOrchestrator) cmd(ctx context.Context, p Project, args ...string) (cmd *exec.Cmd, cleanup func(), err error) {
+	log.Printf("Running '%s %s'...", strings.Join(o.command, " "), args[0])
+
+	cleanup = func() {}
+	file := "-"
+	if !o.stdin {
+		// Relative paths in the compose file are resolved relative to the
+		// directory of the file, so it must be in the working directory.
+		f, err := os.CreateTemp(".", ".docker-compose-*.yml")
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() { os.Remove(f.Name()) }
+		_, err = f.Write(p.ComposeFile)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		file = f.Name()
+	}
+
+	var argv []string
+	argv = append(argv, o.command[1:]...)
+	argv = append(argv, "--project-name", p.Name, "--file", file)
+	argv = append(argv, args...)
+	cmd = exec.CommandContext(ctx, o.command[0], argv...)
+	if o.stdin {
+		cmd.Stdin = bytes.NewReader(p.ComposeFile)
+	}
+	return cmd, cleanup, nil
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// fakeOrchestrator is an Orchestrator that does not run any containers.
+// Instead, Up writes canned result files to the result directory of a project,
+// as the load generator would.
+type fakeOrchestrator struct {
+	Files map[string][]byte // file name to content
+	UpErr map[string]error  // error returned by Up, by run name
+	Hang  map[string]bool   // whether Up blocks until Stop, by run name
+
+	HangBuild map[string]bool // whether Build blocks until ctx is done, by run name
+
+	mu     sync.Mutex
+	Calls  []string                 // like "build baseline"
+	images map[string]bool          // built images
+	stops  map[string]chan struct{} // closed by Stop, by run name
+}
+
+func (o *fakeOrchestrator) record(method string, p Project) string {
+	name := filepath.Base(p.ResultPath)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.Calls = append(o.Calls, method+" "+name)
+	return name
+}
+
+func (o *fakeOrchestrator) Build(ctx context.Context, p Project) error {
+	if name := o.record("build", p); o.HangBuild[name] {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.images == nil {
+		o.images = make(map[string]bool)
+	}
+	for _, ref := range p.Images {
+		o.images[ref] = true
+	}
+	return nil
+}
+
+func (o *fakeOrchestrator) Up(ctx context.Context, p Project, w io.Writer) error {
+	name := o.record("up", p)
+	fmt.Fprintf(w, "fake: up %s\n", p.Name)
+	if err := o.UpErr[name]; err != nil {
+		return err
+	}
+	if o.Hang[name] {
+		select {
+		case <-o.stopped(name):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for file, b := range o.Files {
+		if err := os.WriteFile(filepath.Join(p.ResultPath, file), b, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *fakeOrchestrator) Logs(ctx context.Context, p Project, w io.Writer) error {
+	o.record("logs", p)
+	_, err := fmt.Fprintf(w, "fake: logs %s\n", p.Name)
+	return err
+}
+
+func (o *fakeOrchestrator) Port(ctx context.Context, p Project, service string, port int) (string, error) {
+	return fmt.Sprintf("127.0.0.1:%d", 49000+port), nil
+}
+
+func (o *fakeOrchestrator) Stop(ctx context.Context, p Project, service string, timeout time.Duration) error {
+	name := o.record("stop", p)
+	close(o.stopped(name))
+	return nil
+}
+
+// stopped returns the channel closed by Stop for the run with the given name.
+func (o *fakeOrchestrator) stopped(name string) chan struct{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.stops == nil {
+		o.stops = make(map[string]chan struct{})
+	}
+	if o.stops[name] == nil {
+		o.stops[name] = make(chan struct{})
+	}
+	return o.stops[name]
+}
+
+func (o *fakeOrchestrator) Down(p Project) error {
+	o.record("down", p)
+	return nil
+}
+
+func (o *fakeOrchestrator) Info(ctx context.Context) (RuntimeInfo, error) {
+	return RuntimeInfo{Name: "fake", CPUs: 2, MemoryBytes: 4e9}, nil
+}
+
+func (o *fakeOrchestrator) ImageExists(ctx context.Context, ref string) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.images[ref], nil
+}
+
+func (o *fakeOrchestrator) InspectImage(ctx context.Context, ref string) (Image, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.images[ref] {
+		return Image{}, fmt.Errorf("no such image: %s", ref)
+	}
+	return Image{Ref: ref, ID: "sha256:" + ref}, nil
+}
+
+func (o *fakeOrchestrator) Images(ctx context.Context) ([]Image, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var images []Image
+	for ref := range o.images {
+		images = append(images, Image{Ref: ref})
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Ref < images[j].Ref })
+	return images, nil
+}
+
+func (o *fakeOrchestrator) RemoveImages(ctx context.Context, refs []string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, ref := range refs {
+		delete(o.images, ref)
+	}
+	return nil
+}
+
+// fakeResultFiles returns the files written by loadgen for a test of n
+// requests at 10 requests per second.
+func fakeResultFiles(t *testing.T, n int) map[string][]byte {
+	t.Helper()
+	start := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	var m vegeta.Metrics
+	var results bytes.Buffer
+	gz := gzip.NewWriter(&results)
+	enc := vegeta.NewJSONEncoder(gz)
+	tr := TestResult{
+		Metrics: &m,
+		Results: "results.jsonl.gz",
+		Options: Options{
+			TargetURL:    "http://app:8080/",
+			Model:        "open",
+			RPS:          10,
+			TestDuration: time.Duration(n) * 100 * time.Millisecond,
+		},
+	}
+	for i := 0; i < n; i++ {
+		r := &vegeta.Result{
+			Attack:    "test",
+			Seq:       uint64(i),
+			Code:      200,
+			Timestamp: start.Add(time.Duration(i) * 100 * time.Millisecond),
+			Latency:   time.Duration(i+1) * time.Millisecond,
+			Method:    "GET",
+			URL:       "http://app:8080/",
+		}
+		m.Add(r)
+		if err := enc.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Close()
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hdr bytes.Buffer
+	if err := vegeta.NewHDRHistogramPlotReporter(&m).Report(&hdr); err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{
+		"result.json":      b,
+		"results.jsonl.gz": results.Bytes(),
+		"histogram.hdr":    hdr.Bytes(),
+	}
+}
+
+// chdirPlatform changes the working directory to a new temporary directory
+// with a platform of the given apps, restoring it at the end of the test.
+func chdirPlatform(t *testing.T, apps ...string) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	platform := filepath.Join("platform", "python", "django")
+	for _, app := range apps {
+		if err := os.MkdirAll(filepath.Join(dir, platform, app), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, platform, app, "Dockerfile"), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{
+		filepath.Join(loadgenContext, "Dockerfile"),
+		filepath.Join(relayContext, "Dockerfile"),
+		filepath.Join(databaseContext, "postgres.dockerfile"),
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := `{"target": {"path": "/"}, "rps": 10, "duration": "1s"}`
+	if err := os.WriteFile(filepath.Join(dir, platform, "config.json"), []byte(config), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return platform
+}
+
+func readStatus(t *testing.T, path string) RunStatus {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(path, "status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s statusFile
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s.Status
+}
+
+func TestBenchmarkFakeOrchestrator(t *testing.T) {
+	defer func(b bool) { openBrowser = b }(openBrowser)
+	openBrowser = false
+
+	notReady := runError(StatusNotReady, errors.New("exit status 3"))
+	tests := []struct {
+		Name       string
+		UpErr      map[string]error
+		Hang       map[string]bool
+		HangBuild  map[string]bool
+		RunTimeout time.Duration
+		Deadline   time.Duration // session timeout from the start of the benchmark
+		Canceled   bool
+		Want       map[string]RunStatus
+		WantCalls  []string
+		WantErr    bool
+	}{
+		{
+			Name: "ok",
+			Want: map[string]RunStatus{
+				"baseline":     StatusOK,
+				"instrumented": StatusOK,
+			},
+			WantCalls: []string{
+				"build baseline", "up baseline", "down baseline",
+				"build instrumented", "up instrumented", "down instrumented",
+			},
+		},
+		{
+			Name:  "partial",
+			UpErr: map[string]error{"instrumented": notReady},
+			Want: map[string]RunStatus{
+				"baseline":     StatusOK,
+				"instrumented": StatusNotReady,
+			},
+			WantCalls: []string{
+				"build baseline", "up baseline", "down baseline",
+				"build instrumented", "up instrumented", "logs instrumented", "down instrumented",
+			},
+		},
+		{
+			Name: "failed",
+			UpErr: map[string]error{
+				"baseline":     errors.New("exit status 1"),
+				"instrumented": notReady,
+			},
+			Want: map[string]RunStatus{
+				"baseline":     StatusLoadGenFailed,
+				"instrumented": StatusNotReady,
+			},
+			WantCalls: []string{
+				"build baseline", "up baseline", "logs baseline", "down baseline",
+				"build instrumented", "up instrumented", "logs instrumented", "down instrumented",
+			},
+			WantErr: true,
+		},
+		{
+			Name:       "timed out",
+			Hang:       map[string]bool{"instrumented": true},
+			RunTimeout: 50 * time.Millisecond,
+			Want: map[string]RunStatus{
+				"baseline":     StatusOK,
+				"instrumented": StatusTimedOut,
+			},
+			WantCalls: []string{
+				"build baseline", "up baseline", "down baseline",
+				"build instrumented", "up instrumented", "stop instrumented", "logs instrumented", "down instrumented",
+			},
+		},
+		{
+			Name:     "session timed out",
+			Hang:     map[string]bool{"baseline": true},
+			Deadline: 50 * time.Millisecond,
+			Want: map[string]RunStatus{
+				"baseline":     StatusTimedOut,
+				"instrumented": StatusTimedOut,
+			},
+			WantCalls: []string{
+				"build baseline", "up baseline", "stop baseline", "logs baseline", "down baseline",
+			},
+		},
+		{
+			Name:      "session timed out while building",
+			HangBuild: map[string]bool{"baseline": true},
+			Deadline:  50 * time.Millisecond,
+			Want: map[string]RunStatus{
+				"baseline":     StatusTimedOut,
+				"instrumented": StatusTimedOut,
+			},
+			WantCalls: []string{
+				"build baseline", "down baseline",
+			},
+			WantErr: true,
+		},
+		{
+			Name:     "canceled",
+			Canceled: true,
+			Want: map[string]RunStatus{
+				"baseline":     StatusCanceled,
+				"instrumented": StatusCanceled,
+			},
+			WantErr: true,
+		},
+	}
+	files := fakeResultFiles(t, 20)
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			platform := chdirPlatform(t, "baseline", "instrumented")
+			cfg, err := BenchmarkConfigFromPath(platform)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.RunTimeout = tt.RunTimeout
+			if tt.Deadline > 0 {
+				cfg.Deadline = time.Now().Add(tt.Deadline)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.Canceled {
+				cancel()
+			}
+			o := &fakeOrchestrator{Files: files, UpErr: tt.UpErr, Hang: tt.Hang, HangBuild: tt.HangBuild}
+
+			results, err := Benchmark(ctx, o, cfg)
+			if (err != nil) != tt.WantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.WantErr)
+			}
+
+			got := make(map[string]RunStatus)
+			for _, res := range results {
+				got[res.Name] = statusOf(res.Err)
+				if s := readStatus(t, res.Path); s != got[res.Name] {
+					t.Errorf("%s: status.json has status %q, want %q", res.Name, s, got[res.Name])
+				}
+			}
+			if diff := cmp.Diff(tt.Want, got); diff != "" {
+				t.Errorf("statuses (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.WantCalls, o.Calls); diff != "" {
+				t.Errorf("calls (-want +got):\n%s", diff)
+			}
+			_, err = os.Stat(filepath.Join(cfg.ResultPath(), "report.html"))
+			if gotReport := err == nil; gotReport == tt.WantErr {
+				t.Errorf("got report: %v, want report: %v", gotReport, !tt.WantErr)
+			}
+		})
+	}
+}
+
+func TestBenchmarkImageCache(t *testing.T) {
+	defer func(b bool) { openBrowser = b }(openBrowser)
+	openBrowser = false
+
+	platform := chdirPlatform(t, "baseline", "instrumented")
+	o := &fakeOrchestrator{Files: fakeResultFiles(t, 20)}
+	builds := func() (n int) {
+		for _, c := range o.Calls {
+			if strings.HasPrefix(c, "build ") {
+				n++
+			}
+		}
+		o.Calls = nil
+		return n
+	}
+	benchmark := func(rebuild bool) {
+		t.Helper()
+		cfg, err := BenchmarkConfigFromPath(platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Rebuild = rebuild
+		if _, err := Benchmark(context.Background(), o, cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	benchmark(false)
+	if n := builds(); n != 2 {
+		t.Errorf("first benchmark: got %d builds, want 2", n)
+	}
+	benchmark(false)
+	if n := builds(); n != 0 {
+		t.Errorf("cached benchmark: got %d builds, want 0", n)
+	}
+	benchmark(true)
+	if n := builds(); n != 2 {
+		t.Errorf("rebuild: got %d builds, want 2", n)
+	}
+
+	// Changing the build context of an app invalidates its image.
+	err := os.WriteFile(filepath.Join(platform, "instrumented", "Dockerfile"), []byte("FROM scratch\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	benchmark(false)
+	if diff := cmp.Diff([]string{"build instrumented"}, filterCalls(o.Calls, "build ")); diff != "" {
+		t.Errorf("changed app (-want +got):\n%s", diff)
+	}
+
+	var b bytes.Buffer
+	if err := Cache(context.Background(), o, &b, true); err != nil {
+		t.Fatal(err)
+	}
+	if images, _ := o.Images(context.Background()); len(images) != 0 {
+		t.Errorf("got %d images after prune, want 0", len(images))
+	}
+}
+
+func filterCalls(calls []string, prefix string) []string {
+	var filtered []string
+	for _, c := range calls {
+		if strings.HasPrefix(c, prefix) {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+func TestNewOrchestrator(t *testing.T) {
+	for _, name := range Orchestrators {
+		if _, err := NewOrchestrator(name); err != nil {
+			t.Errorf("NewOrchestrator(%q): %v", name, err)
+		}
+	}
+	if _, err := NewOrchestrator("kubernetes"); err == nil {
+		t.Errorf("NewOrchestrator(%q): got nil error", "kubernetes")
+	}
+}
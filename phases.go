@@ -0,0 +1,97 @@
+package main
+
+import (
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// RequestPhase is the latency of one phase of the requests of a run, averaged
+// over all repetitions.
+type RequestPhase struct {
+	Phase   string // like "Time to first byte"
+	Name    string
+	RPS     uint16
+	Count   uint64 // number of requests that went through the phase, summed over all repetitions
+	Metrics vegeta.LatencyMetrics
+	Diff    *LatencyDiff
+}
+
+// requestPhases are the phases shown in the report, in order.
+var requestPhases = []struct {
+	Name string
+	Get  func(*PhaseMetrics) PhaseLatency
+}{
+	{"Connect", func(m *PhaseMetrics) PhaseLatency { return m.Connect }},
+	{"Time to first byte", func(m *PhaseMetrics) PhaseLatency { return m.TTFB }},
+	{"Body read", func(m *PhaseMetrics) PhaseLatency { return m.BodyRead }},
+}
+
+// getRequestPhases returns the latency of every phase of the requests of every
+// group, ordered by phase. Latencies are compared to the baseline of the same
+// phase and request rate. It returns nil if no test recorded phases.
+func getRequestPhases(groups []*runGroup) []RequestPhase {
+	var phases []RequestPhase
+	for _, phase := range requestPhases {
+		baselines := make(map[uint16]vegeta.LatencyMetrics)
+		for _, g := range groups {
+			m, count, ok := meanPhaseLatency(g.TestResults, phase.Get)
+			if !ok {
+				continue
+			}
+			p := RequestPhase{
+				Phase:   phase.Name,
+				Name:    g.Name,
+				RPS:     g.RPS,
+				Count:   count,
+				Metrics: m,
+			}
+			if g.Name == "baseline" {
+				baselines[g.RPS] = m
+			} else if baseline, ok := baselines[g.RPS]; ok {
+				p.Diff = getLatencyDiff(baseline, m)
+			}
+			phases = append(phases, p)
+		}
+	}
+	return phases
+}
+
+// meanPhaseLatency returns the latency metrics of a phase over the repetitions
+// of a run that recorded phases. Percentiles are the mean over repetitions, as
+// they cannot be merged exactly without the latency of every request, which
+// loadgen does not record per phase.
+func meanPhaseLatency(trs []TestResult, get func(*PhaseMetrics) PhaseLatency) (m vegeta.LatencyMetrics, count uint64, ok bool) {
+	var n time.Duration
+	for _, tr := range trs {
+		if tr.Phases == nil {
+			continue
+		}
+		l := get(tr.Phases)
+		if l.Count == 0 {
+			continue
+		}
+		count += l.Count
+		n++
+		m.Total += l.Total
+		m.P50 += l.P50
+		m.P90 += l.P90
+		m.P95 += l.P95
+		m.P99 += l.P99
+		if l.Max > m.Max {
+			m.Max = l.Max
+		}
+		if l.Min < m.Min || m.Min == 0 {
+			m.Min = l.Min
+		}
+	}
+	if n == 0 {
+		return vegeta.LatencyMetrics{}, 0, false
+	}
+	m.Mean = m.Total / time.Duration(count)
+	m.P50 /= n
+	m.P90 /= n
+	m.P95 /= n
+	m.P99 /= n
+	return m, count, true
+}
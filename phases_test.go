@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func Test_getRequestPhases(t *testing.T) {
+	result := func(ttfb time.Duration) TestResult {
+		return TestResult{Phases: &PhaseMetrics{
+			Connect: PhaseLatency{Count: 1, LatencyMetrics: vegeta.LatencyMetrics{Total: time.Millisecond, P50: time.Millisecond, Min: time.Millisecond, Max: time.Millisecond}},
+			TTFB:    PhaseLatency{Count: 10, LatencyMetrics: vegeta.LatencyMetrics{Total: 10 * ttfb, P50: ttfb, P99: 2 * ttfb, Min: ttfb / 2, Max: 3 * ttfb}},
+		}}
+	}
+	groups := []*runGroup{
+		{Name: "baseline", TestResults: []TestResult{result(10 * time.Millisecond), result(30 * time.Millisecond)}},
+		{Name: "instrumented", TestResults: []TestResult{result(30 * time.Millisecond), {}}},
+	}
+	got := getRequestPhases(groups)
+	if len(got) != 4 {
+		t.Fatalf("got %d rows, want 2 connect and 2 TTFB rows: %+v", len(got), got)
+	}
+	base, instr := got[2], got[3]
+	if base.Phase != "Time to first byte" || base.Count != 20 || base.Diff != nil {
+		t.Errorf("got baseline %+v", base)
+	}
+	if m := base.Metrics; m.Mean != 20*time.Millisecond || m.P99 != 40*time.Millisecond || m.Min != 5*time.Millisecond || m.Max != 90*time.Millisecond {
+		t.Errorf("got baseline metrics %+v", m)
+	}
+	if instr.Count != 10 || instr.Diff == nil || instr.Diff.P50 != 50 {
+		t.Errorf("got instrumented %+v, want 50%% higher median than baseline", instr)
+	}
+	if got[1].Diff == nil || got[1].Diff.P50 != 0 {
+		t.Errorf("got instrumented connect %+v, want no difference to baseline", got[1])
+	}
+
+	var b bytes.Buffer
+	if err := reportTemplate.Execute(&b, ReportFile{RequestPhases: got}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Time to first byte") {
+		t.Errorf("report does not contain the time to first byte")
+	}
+
+	// Results of older versions of loadgen have no phases.
+	if got := getRequestPhases([]*runGroup{{Name: "baseline", TestResults: []TestResult{{}}}}); got != nil {
+		t.Errorf("got %+v for results without phases, want nil", got)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Defaults of loadgen flags that the platform configuration may leave unset.
+//
+// Copied from ./tool/loadgen.
+const (
+	loadgenDefaultMaxWait = 30 * time.Second
+	loadgenDefaultWarmup  = 15 * time.Second
+)
+
+// EstimatedRunTime returns an upper bound of the time it takes to run an app
+// once, assuming that the app becomes ready within the max wait time. It does
+// not include building images nor the capacity search.
+//
+// EstimatedRunTime must be called with a valid configuration.
+func (cfg PlatformConfig) EstimatedRunTime() time.Duration {
+	d, _ := time.ParseDuration(cfg.Duration)
+	warmup := loadgenDefaultWarmup
+	if cfg.Warmup != "" {
+		warmup, _ = time.ParseDuration(cfg.Warmup)
+	}
+	maxWait := loadgenDefaultMaxWait
+	if cfg.MaxWait != "" {
+		maxWait, _ = time.ParseDuration(cfg.MaxWait)
+	}
+	return maxWait + warmup + d
+}
+
+// Plan writes the execution plan of a benchmark to w, listing the runs in the
+// order in which they would run. Plan renders the Docker Compose file of every
+// run, but does not run anything nor write any files.
+//
+// Plan returns an error if any run cannot be planned, e.g. because an app has
+// no Dockerfile. Runs that can be planned are written to w regardless.
+func Plan(w io.Writer, cfg BenchmarkConfig) error {
+	schedule := cfg.Schedule()
+	estimate := time.Duration(len(schedule)) * cfg.PlatformConfig.EstimatedRunTime()
+	runs := "runs"
+	if len(schedule) == 1 {
+		runs = "run"
+	}
+	fmt.Fprintf(w, "%s: %d %s, estimated time %v (excluding image builds", cfg.Platform, len(schedule), runs, estimate)
+	if cfg.PlatformConfig.Capacity != nil {
+		fmt.Fprint(w, " and capacity search")
+	}
+	fmt.Fprintln(w, ")")
+	fmt.Fprintf(w, "  result:     %s\n", cfg.ResultPath())
+
+	var failed int
+	for i, runCfg := range schedule {
+		plan, err := planRun(cfg, runCfg)
+		fmt.Fprintf(w, "  %d. %s\n", i+1, plan.Result.Label())
+		if err != nil {
+			fmt.Fprintf(w, "     error:      %s\n", err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "     project:    %s\n", plan.Project.Name)
+		fmt.Fprintf(w, "     dockerfile: %s\n", plan.Dockerfile)
+		fmt.Fprintf(w, "     image:      %s\n", plan.Images.App)
+		fmt.Fprintf(w, "     result:     %s\n", plan.Result.Path)
+		fmt.Fprintf(w, "     loadgen:    %s\n", shellJoin(append([]string{"loadgen"}, plan.LoadGenArgs...)))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d runs cannot be planned", failed, len(schedule))
+	}
+	return nil
+}
+
+var shellSafeRegex = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellJoin returns args as a command line for a POSIX shell, quoting
+// arguments as needed.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if shellSafeRegex.MatchString(a) {
+			quoted[i] = a
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
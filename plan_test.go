@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlan(t *testing.T) {
+	cfg, err := BenchmarkConfigFromPath(filepath.FromSlash("testdata/platform/python/flask"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Count = 2
+	var b bytes.Buffer
+	if err := Plan(&b, cfg); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"6 runs, estimated time 7m30s",
+		"python-flask-instrumented-0-1-" + cfg.ID.String(),
+		"dockerfile: testdata/platform/python/flask/instrumented/Dockerfile",
+		"loadgen:    loadgen -target 'http://app:8080/update?queries=10' -rps 10 -test 30s",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan does not contain %q:\n%s", want, out)
+		}
+	}
+
+	// Plan reports runs that cannot run, without stopping.
+	cfg.Runs = append(cfg.Runs, RunConfig{Name: "missing", App: "missing"})
+	b.Reset()
+	if err := Plan(&b, cfg); err == nil {
+		t.Errorf("got nil error for app without Dockerfile")
+	}
+	if got := strings.Count(b.String(), "project:"); got != 6 {
+		t.Errorf("got %d planned runs, want 6", got)
+	}
+}
+
+func TestEstimatedRunTime(t *testing.T) {
+	tests := []struct {
+		Duration, Warmup, MaxWait string
+		Want                      time.Duration
+	}{
+		{"30s", "", "", 75 * time.Second},
+		{"1m", "0s", "2m", 3 * time.Minute},
+		{"10s", "5s", "5s", 20 * time.Second},
+	}
+	for _, tt := range tests {
+		cfg := PlatformConfig{Duration: tt.Duration, Warmup: tt.Warmup, MaxWait: tt.MaxWait}
+		if got := cfg.EstimatedRunTime(); got != tt.Want {
+			t.Errorf("EstimatedRunTime(%+v) = %v, want %v", tt, got, tt.Want)
+		}
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	tests := []struct {
+		In   []string
+		Want string
+	}{
+		{[]string{"loadgen", "-rps", "10"}, "loadgen -rps 10"},
+		{[]string{"-target", "http://app:8080/update?queries=10"}, "-target 'http://app:8080/update?queries=10'"},
+		{[]string{"a b", "it's", ""}, `'a b' 'it'\''s' ''`},
+	}
+	for _, tt := range tests {
+		if got := shellJoin(tt.In); got != tt.Want {
+			t.Errorf("shellJoin(%q) = %q, want %q", tt.In, got, tt.Want)
+		}
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,33 +36,17 @@ func init() {
 //
 // Must be called with 1 or more valid result paths.
 func Report(s []string) {
-	entries, err := os.ReadDir(s[0])
-	if err != nil {
-		panic(err)
-	}
-
-	// Generate run results from folder names
 	var runResults []*RunResult
 	hasMultipleResults := len(s) > 1
 
 	for _, resultPath := range s {
-		for _, e := range entries {
-			if !e.IsDir() {
-				continue
-			}
-			name := e.Name()
-			path := filepath.Join(resultPath, name)
-
+		for _, res := range findRunResults(resultPath) {
 			// If there is multiple results, we need to uniquely identify them by more than just
 			// their name (baseline, instrumented), so we rely on the entire folder path.
 			if hasMultipleResults {
-				name = path
+				res.Name = filepath.Join(resultPath, res.Name)
 			}
-
-			runResults = append(runResults, &RunResult{
-				Name: name,
-				Path: path,
-			})
+			runResults = append(runResults, res)
 		}
 	}
 
@@ -68,60 +54,198 @@ func Report(s []string) {
 		panic(fmt.Errorf("no valid results in: %s", s))
 	}
 
-	report(runResults)
+	if err := report(s[0], runResults); err != nil {
+		panic(err)
+	}
+}
+
+// findRunResults returns the runs stored in the result directory of a
+// benchmark. Results of repeated benchmarks are stored in one subdirectory per
+// repetition, and results of rate sweeps in one subdirectory per rate.
+func findRunResults(path string) []*RunResult {
+	var results []*RunResult
+	for _, name := range subDirs(path) {
+		p := filepath.Join(path, name)
+		if rep, err := strconv.Atoi(name); err == nil {
+			for _, res := range findRunResults(p) {
+				res.Repetition = rep
+				results = append(results, res)
+			}
+			continue
+		}
+		if rps, ok := parseRPSDir(name); ok {
+			for _, res := range findRunResults(p) {
+				res.RPS = rps
+				results = append(results, res)
+			}
+			continue
+		}
+		// Failed runs leave a result directory without a result.
+		if _, err := os.Stat(filepath.Join(p, "result.json")); err != nil {
+			log.Printf("Skipping %q: no result", p)
+			continue
+		}
+		results = append(results, &RunResult{
+			Name: name,
+			App:  name,
+			Path: p,
+		})
+	}
+	return results
+}
+
+// parseRPSDir parses the name of a directory holding the results of one
+// request rate of a sweep, like "100rps".
+func parseRPSDir(name string) (uint16, bool) {
+	if !strings.HasSuffix(name, "rps") {
+		return 0, false
+	}
+	rps, err := strconv.ParseUint(strings.TrimSuffix(name, "rps"), 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(rps), true
 }
 
-func report(results []*RunResult) {
+// runGroup holds the results of all repetitions of a run.
+type runGroup struct {
+	Name        string
+	RPS         uint16
+	TestResults []TestResult
+	Latencies   vegeta.LatencyMetrics
+}
+
+// report writes an HTML report to the given result path. All repetitions of a
+// run are aggregated into a single row of the latency table.
+func report(path string, results []*RunResult) error {
 	reportFile := ReportFile{
-		ID:        filepath.Base(filepath.Dir(results[0].Path)),
+		ID:        filepath.Base(path),
+		Title:     path,
 		ReportCSS: reportCSS,
 		ReportJS:  reportJS,
-		Latency: []Latency{
-			{
-				Name: "baseline",
-				Diff: nil,
-			},
-		},
 	}
 
-	// Extract out baseline as order of run results is unknown
-	var baselineResult TestResult
-	for _, res := range results {
-		if res.Name == "baseline" {
-			baselineResult = readTestResult(filepath.Join(res.Path, "result.json"))
+	// Group repetitions, preserving the order in which groups first appear.
+	var groups []*runGroup
+	trs := make([]TestResult, len(results))
+	byName := make(map[string]*runGroup)
+	for i, res := range results {
+		g, ok := byName[res.Group()]
+		if !ok {
+			g = &runGroup{Name: res.Name, RPS: res.RPS}
+			byName[res.Group()] = g
+			groups = append(groups, g)
 		}
+		tr, err := readTestResult(filepath.Join(res.Path, "result.json"))
+		if err != nil {
+			return err
+		}
+		trs[i] = tr
+		g.TestResults = append(g.TestResults, trs[i])
 	}
+	// Order by rate, with the baseline first.
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].RPS != groups[j].RPS {
+			return groups[i].RPS < groups[j].RPS
+		}
+		return groups[i].Name == "baseline" && groups[j].Name != "baseline"
+	})
 
-	p := plot.New()
-	for i, res := range results {
-		folderPath := res.Path
-		name := res.Name
+	// Extract out baseline as order of run results is unknown
+	baselines := make(map[uint16]*runGroup)
+	for _, g := range groups {
+		latencies, err := aggregateLatencies(g.TestResults)
+		if err != nil {
+			return err
+		}
+		g.Latencies = latencies
+		if g.Name == "baseline" {
+			baselines[g.RPS] = g
+		}
+	}
+	for _, g := range groups {
+		latency := Latency{
+			Name:       g.Name,
+			Runs:       len(g.TestResults),
+			RPS:        g.RPS,
+			Metrics:    g.Latencies,
+			Throughput: meanThroughput(g.TestResults),
+		}
+		if sdkInfo, ok := groupSDKInfo(g); ok {
+			latency.SdkVersion = sdkInfo.Version
+		}
+		if cpu, ok := meanAppCPUUsage(g.TestResults); ok {
+			latency.CPU = cpu
+		}
+		if baseline, ok := baselines[g.RPS]; ok && g.Name != "baseline" {
+			latency.Diff = getLatencyDiff(baseline.Latencies, latency.Metrics)
+			if throughput := meanThroughput(baseline.TestResults); throughput > 0 {
+				throughputDiff := percentDiffFloat(throughput, latency.Throughput)
+				latency.ThroughputDiff = &throughputDiff
+			}
+			if cpu, ok := meanAppCPUUsage(baseline.TestResults); ok && cpu > 0 && latency.CPU > 0 {
+				cpuDiff := percentDiffFloat(cpu, latency.CPU)
+				latency.CPUDiff = &cpuDiff
+			}
+		}
+		reportFile.Latency = append(reportFile.Latency, latency)
+		if g.RPS > 0 && (len(reportFile.Rates) == 0 || reportFile.Rates[len(reportFile.Rates)-1] != uint(g.RPS)) {
+			reportFile.Rates = append(reportFile.Rates, uint(g.RPS))
+		}
+	}
 
-		if i == 0 {
-			reportFile.Title = folderPath
+	if b, err := os.ReadFile(filepath.Join(path, "overrides.json")); err == nil {
+		if err := json.Unmarshal(b, &reportFile.Overrides); err != nil {
+			return err
 		}
+	}
 
-		var data ResultData
-		data.Name = name
-		data.HDR = string(readBytes(filepath.Join(folderPath, "histogram.hdr")))
+	env, err := readEnvironment(path)
+	if err != nil {
+		return err
+	}
+	reportFile.Environment = env
+
+	reportFile.Capacity = getCapacity(groups)
+	reportFile.EndpointLatency, err = getEndpointLatencies(groups)
+	if err != nil {
+		return err
+	}
+	reportFile.RequestPhases = getRequestPhases(groups)
+	reportFile.ContainerIO = getContainerIO(groups)
 
-		tr := readTestResult(filepath.Join(folderPath, "result.json"))
+	if len(reportFile.Rates) > 1 {
+		var err error
+		reportFile.SweepLatencyPlot, reportFile.SweepCPUPlot, err = sweepCharts(groups)
+		if err != nil {
+			return err
+		}
+	}
 
-		if name == "baseline" {
-			reportFile.Latency[0].Metrics = tr.Latencies
-		} else {
-			latency := Latency{
-				Name:    name,
-				Metrics: tr.Latencies,
-			}
-			if baselineResult.Metrics != nil {
-				latency.Diff = getLatencyDiff(baselineResult.Latencies, tr.Latencies)
-			}
-			reportFile.Latency = append(reportFile.Latency, latency)
+	opts := []plot.Opt{plot.OfferedRate()}
+	if len(reportFile.EndpointLatency) > 0 {
+		opts = append(opts, plot.Label(endpointLabeler))
+	}
+	p := plot.New(opts...)
+	for i, res := range results {
+		folderPath := res.Path
+		name := res.Label()
 
+		var data ResultData
+		data.Name = name
+		data.RunName = res.Name
+		data.App = res.App
+		hdr, err := os.ReadFile(filepath.Join(folderPath, "histogram.hdr"))
+		if err != nil {
+			return err
 		}
+		data.HDR = string(hdr)
+
+		tr := trs[i]
 
-		if math.Round(tr.Throughput) != math.Round(tr.Rate) {
+		// In the closed model the request rate is not configured, but a
+		// consequence of how fast the target responds.
+		if tr.Options.Model != "closed" && math.Round(tr.Throughput) != math.Round(tr.Rate) {
 			data.ThroughputDifferent = true
 		}
 
@@ -131,9 +255,12 @@ func report(results []*RunResult) {
 			reportFile.HasErrors = true
 		}
 
-		for _, r := range tr.LoadGenResult {
+		err = tr.eachResult(func(r *vegeta.Result) {
 			r.Attack = name
 			p.Add(r)
+		})
+		if err != nil {
+			return err
 		}
 
 		data.TestResult = tr
@@ -142,20 +269,28 @@ func report(results []*RunResult) {
 		reportFile.Data = append(reportFile.Data, data)
 	}
 
-	// FIXME: AppDetails might be different per run. For now, this takes the
-	// first non-empty value.
-	for _, data := range reportFile.Data {
-		sdkInfo := data.TestResult.RelayMetrics.SDKInfo
-		var empty SDKInfo
-		if sdkInfo != empty {
-			reportFile.AppDetails = getAppDetails(results[0].Path, sdkInfo)
-			break
+	// AppDetails might be different per run, e.g. when running a matrix of
+	// SDK versions. This takes the first non-empty value of every group.
+	for _, g := range groups {
+		if sdkInfo, ok := groupSDKInfo(g); ok {
+			details := getAppDetails(path, sdkInfo)
+			details.Name = g.Name
+			details.RPS = g.RPS
+			reportFile.AppDetails = append(reportFile.AppDetails, details)
 		}
 	}
 
 	plotData, err := p.GetData()
 	if err != nil {
-		panic(err)
+		return err
+	}
+	// Offered rate series are drawn on the secondary axis, such that
+	// latency spikes can be correlated with bursts of traffic.
+	rateSeries := make(map[string]DygraphsSeriesOpts)
+	for _, label := range plotData.Labels {
+		if strings.HasSuffix(label, ": "+plot.RateLabel) {
+			rateSeries[label] = DygraphsSeriesOpts{Axis: "y2"}
+		}
 	}
 	// TODO(abhi): have a global list of ids we can refer to.
 	// TODO(vladan): make a chart width responsive 100%
@@ -172,31 +307,42 @@ func report(results []*RunResult) {
 			StrokeWidth: 1.3,
 			Width:       1500,
 			RollPeriod:  5,
+			Y2Label:     "Offered rate (requests/s)",
+			Series:      rateSeries,
 		},
 	)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	reportPath := filepath.Join(filepath.Dir(reportFile.Title), "report.html")
+	reportFile.CPUTimePlot, reportFile.MemoryTimePlot, err = resourceCharts(reportFile.Data)
+	if err != nil {
+		return err
+	}
+
+	reportPath := filepath.Join(path, "report.html")
 
 	f, err := os.Create(reportPath)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer f.Close()
 
 	log.Printf("Writing benchmark report to %q", reportPath)
 	if err := reportTemplate.Execute(f, reportFile); err != nil {
-		panic(err)
+		return err
 	}
 
 	if sanityCheckMode {
-		sanityCheck(reportFile.Data)
+		if err := sanityCheck(reportFile.Data); err != nil {
+			return err
+		}
 	}
 
 	if openBrowser {
 		browser.Open(reportPath)
 	}
+	return nil
 }
 
 type ReportFile struct {
@@ -209,12 +355,38 @@ type ReportFile struct {
 	ReportCSS   []template.CSS
 	ReportJS    []template.HTML
 
-	AppDetails     AppDetails
+	AppDetails     []AppDetails
+	Overrides      map[string]string // platform configuration overridden from the command line, by flag name
+	Environment    *Environment      // host that ran the benchmark, nil for older results
 	LoadGenOptions Options
 	Latency        []Latency
+	Capacity       []Capacity
+	// EndpointLatency breaks down latency by endpoint. It is empty unless
+	// the platform configures a list of targets.
+	EndpointLatency []EndpointLatency
+	// RequestPhases breaks down latency by phase of the requests. It is
+	// empty for results of older versions of loadgen.
+	RequestPhases []RequestPhase
+
+	// Rates lists the request rates of a sweep in ascending order. It is
+	// empty unless apps ran at more than one rate.
+	Rates            []uint
+	SweepLatencyPlot template.HTML
+	SweepCPUPlot     template.HTML
+
+	// CPUTimePlot and MemoryTimePlot chart the resource usage of the
+	// containers over time. They are empty unless loadgen sampled stats.
+	CPUTimePlot    template.HTML
+	MemoryTimePlot template.HTML
+
+	// ContainerIO lists the network and disk I/O of the containers of
+	// every run. It is empty unless loadgen collected I/O stats.
+	ContainerIO []ContainerIO
 }
 
 type AppDetails struct {
+	Name       string // name of the run
+	RPS        uint16 // request rate of the run, only set for sweeps
 	Language   string
 	Framework  string
 	SdkName    string
@@ -222,7 +394,9 @@ type AppDetails struct {
 }
 
 type ResultData struct {
-	Name                string
+	Name                string // unique label of the run, e.g. "baseline #2"
+	RunName             string // name of the run, e.g. "baseline"
+	App                 string // app of the run, e.g. "instrumented" for "instrumented@0.1"
 	HDR                 string
 	TestResult          TestResult
 	TestResultJSON      string
@@ -243,8 +417,30 @@ type SDKInfo struct {
 
 type Latency struct {
 	Name    string                `json:"name"`
+	Runs    int                   `json:"runs"`
+	RPS     uint16                `json:"rps,omitempty"`
 	Diff    *LatencyDiff          `json:"diff,omitempty"`
 	Metrics vegeta.LatencyMetrics `json:"metrics"`
+	// Throughput is the mean rate of successful requests per second.
+	Throughput     float64  `json:"throughput"`
+	ThroughputDiff *float64 `json:"throughput_diff,omitempty"`
+	// CPU is the mean CPU usage of the app, in percent of one CPU core. It
+	// is zero if container stats are not available.
+	CPU        float64  `json:"cpu,omitempty"`
+	CPUDiff    *float64 `json:"cpu_diff,omitempty"`
+	SdkVersion string   `json:"sdk_version,omitempty"`
+}
+
+// Capacity is the maximum sustainable throughput of a run, averaged over all
+// repetitions.
+type Capacity struct {
+	Name string
+	RPS  uint16 // request rate of the test, only set for sweeps
+	// Capacity is the highest request rate at which the app met the SLO.
+	Capacity float64
+	// Lost is the percentage of the baseline capacity lost to
+	// instrumentation. It is nil for the baseline itself.
+	Lost *float64
 }
 
 // LatencyDiff stores the percentage difference between
@@ -272,13 +468,28 @@ type LatencyDiff struct {
 
 type Options struct {
 	TargetURL      string        `json:"target_url"`
+	Targets        string        `json:"targets,omitempty"`
+	StatsSource    string        `json:"stats_source,omitempty"`
 	CAdvisorURL    string        `json:"cadvisor_url"`
+	DockerSocket   string        `json:"docker_socket,omitempty"`
+	CgroupRoot     string        `json:"cgroup_root,omitempty"`
+	ProcRoot       string        `json:"proc_root,omitempty"`
 	FakerelayURL   string        `json:"fakerelay_url"`
 	Containers     string        `json:"containers"`
 	MaxWait        time.Duration `json:"max_wait"`
 	WarmupDuration time.Duration `json:"warmup_duration"`
 	TestDuration   time.Duration `json:"test_duration"`
+	Model          string        `json:"model"` // "open" or "closed"
 	RPS            uint          `json:"rps"`
+	Profile        string        `json:"profile,omitempty"`
+	Concurrency    uint          `json:"concurrency"`
+	ThinkTime      time.Duration `json:"think_time"`
+	Capacity       bool          `json:"capacity"`
+	SLOSuccess     float64       `json:"slo_success"`
+	SLOP99         time.Duration `json:"slo_p99"`
+	CapacityStep   time.Duration `json:"capacity_step"`
+	CapacityMax    uint          `json:"capacity_max"`
+	SampleInterval time.Duration `json:"sample_interval"`
 	Out            string        `json:"out"`
 }
 
@@ -286,33 +497,91 @@ type Options struct {
 type TestResult struct {
 	FirstAppResponse string
 	*vegeta.Metrics
-	LoadGenResult  []*vegeta.Result `json:"loadgen_result"`
+	Results        string           `json:"results,omitempty"` // name of the file with the result of every request, see resultsFile
 	Stats          map[string]Stats `json:"container_stats"`
 	RelayMetrics   RelayMetrics     `json:"relay_metrics,omitempty"`
 	LoadGenCommand string           `json:"loadgen_command"`
 	Options        Options          `json:"options"`
+	Capacity       *CapacityResult  `json:"capacity,omitempty"`
+	Endpoints      []EndpointResult `json:"endpoints,omitempty"`
+	Phases         *PhaseMetrics    `json:"phases,omitempty"`
+	Stopped        bool             `json:"stopped,omitempty"` // stopped early, the test is incomplete
+
+	// LoadGenResult is the result of every request in results of older
+	// versions of loadgen, which did not write a results file.
+	LoadGenResult []*vegeta.Result `json:"loadgen_result,omitempty"`
+}
+
+type EndpointResult struct {
+	Endpoint string `json:"endpoint"`
+	Weight   uint   `json:"weight"`
+	*vegeta.Metrics
+	HDR string `json:"hdr"`
+}
+
+type PhaseMetrics struct {
+	Connect  PhaseLatency `json:"connect"`
+	TTFB     PhaseLatency `json:"ttfb"`
+	BodyRead PhaseLatency `json:"body_read"`
+}
+
+type PhaseLatency struct {
+	Count uint64 `json:"count"`
+	vegeta.LatencyMetrics
+}
+
+type CapacityResult struct {
+	RPS   uint           `json:"rps"`
+	Steps []CapacityStep `json:"steps"`
+}
+
+type CapacityStep struct {
+	RPS        uint          `json:"rps"`
+	Success    float64       `json:"success"`
+	P99        time.Duration `json:"99th"`
+	Throughput float64       `json:"throughput"`
+	OK         bool          `json:"ok"`
 }
 
 type Stats struct {
 	Before     ContainerStats           `json:"before"`
 	After      ContainerStats           `json:"after"`
 	Difference ContainerStatsDifference `json:"difference"`
+	Samples    []ContainerStats         `json:"samples,omitempty"` // sampled during warmup and test
 }
 
 type ContainerStats struct {
 	Timestamp           time.Time `json:"timestamp"`
+	MemoryUsageBytes    uint64    `json:"memory_usage_bytes"`
 	MemoryMaxUsageBytes uint64    `json:"memory_max_usage_bytes"`
-	CPUUsageUser        uint64    `json:"cpu_usage_user"`
-	CPUUsageSystem      uint64    `json:"cpu_usage_system"`
-	CPUUsageTotal       uint64    `json:"cpu_usage_total"`
+	// MemoryWorkingSetBytes is the memory usage without inactive file
+	// cache, which the kernel can reclaim.
+	MemoryWorkingSetBytes uint64 `json:"memory_working_set_bytes"`
+	CPUUsageUser          uint64 `json:"cpu_usage_user"`
+	CPUUsageSystem        uint64 `json:"cpu_usage_system"`
+	CPUUsageTotal         uint64 `json:"cpu_usage_total"`
+	// Network counters exclude the loopback interface.
+	NetworkRxBytes   uint64 `json:"network_rx_bytes"`
+	NetworkRxPackets uint64 `json:"network_rx_packets"`
+	NetworkTxBytes   uint64 `json:"network_tx_bytes"`
+	NetworkTxPackets uint64 `json:"network_tx_packets"`
+	BlockReadBytes   uint64 `json:"block_read_bytes"`
+	BlockWriteBytes  uint64 `json:"block_write_bytes"`
 }
 
 type ContainerStatsDifference struct {
-	Duration            time.Duration `json:"duration"`
-	MemoryMaxUsageBytes int64         `json:"memory_max_usage_bytes"`
-	CPUUsageUser        int64         `json:"cpu_usage_user"`
-	CPUUsageSystem      int64         `json:"cpu_usage_system"`
-	CPUUsageTotal       int64         `json:"cpu_usage_total"`
+	Duration              time.Duration `json:"duration"`
+	MemoryMaxUsageBytes   int64         `json:"memory_max_usage_bytes"`
+	MemoryWorkingSetBytes int64         `json:"memory_working_set_bytes"`
+	CPUUsageUser          int64         `json:"cpu_usage_user"`
+	CPUUsageSystem        int64         `json:"cpu_usage_system"`
+	CPUUsageTotal         int64         `json:"cpu_usage_total"`
+	NetworkRxBytes        int64         `json:"network_rx_bytes"`
+	NetworkRxPackets      int64         `json:"network_rx_packets"`
+	NetworkTxBytes        int64         `json:"network_tx_bytes"`
+	NetworkTxPackets      int64         `json:"network_tx_packets"`
+	BlockReadBytes        int64         `json:"block_read_bytes"`
+	BlockWriteBytes       int64         `json:"block_write_bytes"`
 }
 
 // END copied from ./tool/loadgen
@@ -342,13 +611,18 @@ func readBytes(path string) []byte {
 	return b
 }
 
-func readTestResult(path string) (tr TestResult) {
-	if err := json.Unmarshal(readBytes(path), &tr); err != nil {
-		panic(err)
+func readTestResult(path string) (tr TestResult, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return tr, err
+	}
+	if err := json.Unmarshal(b, &tr); err != nil {
+		return tr, fmt.Errorf("%s: %w", path, err)
 	}
+	tr.resolveResults(path)
 	tr.FirstAppResponse = formatHTTP(tr.FirstAppResponse)
 	tr.RelayMetrics.FirstRequest = formatHTTP(tr.RelayMetrics.FirstRequest)
-	return tr
+	return tr, nil
 }
 
 func marshalToStr(t interface{}) string {
@@ -418,6 +692,83 @@ func getAppDetails(path string, sdkInfo SDKInfo) AppDetails {
 	}
 }
 
+// groupSDKInfo returns the first non-empty SDK information reported by the
+// runs of a group.
+func groupSDKInfo(g *runGroup) (SDKInfo, bool) {
+	var empty SDKInfo
+	for _, tr := range g.TestResults {
+		if sdkInfo := tr.RelayMetrics.SDKInfo; sdkInfo != empty {
+			return sdkInfo, true
+		}
+	}
+	return empty, false
+}
+
+// aggregateLatencies returns latency metrics computed over the results of all
+// repetitions of a run.
+func aggregateLatencies(trs []TestResult) (vegeta.LatencyMetrics, error) {
+	if len(trs) == 1 {
+		return trs[0].Latencies, nil
+	}
+	var m vegeta.Metrics
+	for _, tr := range trs {
+		if err := tr.eachResult(m.Add); err != nil {
+			return vegeta.LatencyMetrics{}, err
+		}
+	}
+	m.Close()
+	return m.Latencies, nil
+}
+
+// getCapacity returns the capacity of all groups that ran a capacity search.
+func getCapacity(groups []*runGroup) []Capacity {
+	var capacity []Capacity
+	baseline := make(map[uint16]float64)
+	for _, g := range groups {
+		var sum float64
+		var n int
+		for _, tr := range g.TestResults {
+			if tr.Capacity != nil {
+				sum += float64(tr.Capacity.RPS)
+				n++
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		c := Capacity{
+			Name:     g.Name,
+			RPS:      g.RPS,
+			Capacity: sum / float64(n),
+		}
+		if g.Name == "baseline" {
+			baseline[g.RPS] = c.Capacity
+		} else if b, ok := baseline[g.RPS]; ok && b > 0 {
+			lost := math.Round((b-c.Capacity)/b*100*100) / 100
+			c.Lost = &lost
+		}
+		capacity = append(capacity, c)
+	}
+	return capacity
+}
+
+// meanThroughput returns the mean throughput over the repetitions of a run that
+// have metrics, or 0 if none has.
+func meanThroughput(trs []TestResult) float64 {
+	var sum float64
+	var n int
+	for _, tr := range trs {
+		if tr.Metrics != nil {
+			sum += tr.Throughput
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
 func getLatencyDiff(baseline, final vegeta.LatencyMetrics) *LatencyDiff {
 	return &LatencyDiff{
 		Total: percentDiff(baseline.Total, final.Total),
@@ -439,6 +790,11 @@ func percentDiff(start, final time.Duration) float64 {
 	return math.Round(p*100) / 100
 }
 
+func percentDiffFloat(start, final float64) float64 {
+	p := ((final - start) / start) * 100
+	return math.Round(p*100) / 100
+}
+
 // formatHTTP takes a raw HTTP 1.x request or response and pretty-prints JSON
 // bodies.
 func formatHTTP(b string) string {
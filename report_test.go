@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
 func Test_formatSDKName(t *testing.T) {
@@ -219,3 +220,56 @@ func TestFormatHTTP(t *testing.T) {
 		})
 	}
 }
+
+func Test_getCapacity(t *testing.T) {
+	result := func(rps uint) TestResult {
+		return TestResult{Capacity: &CapacityResult{RPS: rps}}
+	}
+	lost := func(v float64) *float64 { return &v }
+	groups := []*runGroup{
+		{Name: "baseline", RPS: 10, TestResults: []TestResult{result(300), result(500)}},
+		{Name: "instrumented", RPS: 10, TestResults: []TestResult{result(300), {}}},
+		{Name: "baseline", RPS: 20, TestResults: []TestResult{result(0)}},
+		{Name: "instrumented", RPS: 20, TestResults: []TestResult{result(100)}},
+		{Name: "other", RPS: 30, TestResults: []TestResult{result(100)}},
+		{Name: "no capacity", RPS: 10, TestResults: []TestResult{{}}},
+	}
+	want := []Capacity{
+		{Name: "baseline", RPS: 10, Capacity: 400},
+		{Name: "instrumented", RPS: 10, Capacity: 300, Lost: lost(25)},
+		// A baseline that never met the SLO has no capacity to lose.
+		{Name: "baseline", RPS: 20, Capacity: 0},
+		{Name: "instrumented", RPS: 20, Capacity: 100},
+		// No baseline at the same rate.
+		{Name: "other", RPS: 30, Capacity: 100},
+	}
+	if diff := cmp.Diff(want, getCapacity(groups)); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func Test_meanThroughput(t *testing.T) {
+	result := func(throughput float64) TestResult {
+		return TestResult{Metrics: &vegeta.Metrics{Throughput: throughput}}
+	}
+	tests := []struct {
+		Name string
+		TRs  []TestResult
+		Want float64
+	}{
+		{Name: "all", TRs: []TestResult{result(10), result(20)}, Want: 15},
+		// Repetitions without metrics, e.g. timed out before the test,
+		// do not count.
+		{Name: "partial", TRs: []TestResult{result(10), {}, result(20)}, Want: 15},
+		{Name: "none", TRs: []TestResult{{}, {}}, Want: 0},
+		{Name: "empty", Want: 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			if got := meanThroughput(tt.TRs); got != tt.Want {
+				t.Errorf("got %v, want %v", got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+)
+
+// resourceContainers are the containers whose resource usage is charted over
+// time, by image name.
+var resourceContainers = []string{"app", "postgres", "fakerelay"}
+
+// resourceSeries is the usage of a container over time.
+type resourceSeries struct {
+	Label  string
+	Points [][2]float64 // seconds since the start of the test and value
+}
+
+// resourceCharts creates charts of CPU and memory usage over time of the
+// containers of every run, from the stats that loadgen sampled during warmup
+// and test. Times are relative to the start of the test, such that warmup has
+// negative times. The charts are empty if no run has samples.
+func resourceCharts(data []ResultData) (cpu, memory template.HTML, err error) {
+	cpuSeries, memorySeries := resourceUsage(data)
+	if len(cpuSeries) == 0 && len(memorySeries) == 0 {
+		return "", "", nil
+	}
+
+	cpu, err = timeChart("cpuTimePlot", cpuSeries, DygraphsOpts{
+		Title:  "CPU Usage over Time",
+		YLabel: "CPU usage (%)",
+	})
+	if err != nil {
+		return "", "", err
+	}
+	memory, err = timeChart("memoryTimePlot", memorySeries, DygraphsOpts{
+		Title:  "Memory Usage over Time",
+		YLabel: "Memory usage (MB)",
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return cpu, memory, nil
+}
+
+// resourceUsage returns the CPU usage, in percent of one CPU core, and the
+// memory usage, in MB, of the containers of every run. CPU usage is the mean
+// between consecutive samples.
+func resourceUsage(data []ResultData) (cpu, memory []resourceSeries) {
+	for _, d := range data {
+		for _, name := range resourceContainers {
+			stats, ok := d.TestResult.Stats[name]
+			if !ok || len(stats.Samples) == 0 {
+				continue
+			}
+			start := stats.Before.Timestamp
+			if start.IsZero() {
+				start = stats.Samples[0].Timestamp
+			}
+			c := resourceSeries{Label: d.Name + ": " + name}
+			m := resourceSeries{Label: d.Name + ": " + name}
+			for i, s := range stats.Samples {
+				t := s.Timestamp.Sub(start).Seconds()
+				m.Points = append(m.Points, [2]float64{t, float64(s.MemoryUsageBytes) / 1e6})
+				if i == 0 {
+					continue
+				}
+				prev := stats.Samples[i-1]
+				elapsed := s.Timestamp.Sub(prev.Timestamp)
+				if elapsed <= 0 || s.CPUUsageTotal < prev.CPUUsageTotal {
+					continue
+				}
+				c.Points = append(c.Points, [2]float64{t, float64(s.CPUUsageTotal-prev.CPUUsageTotal) / float64(elapsed) * 100})
+			}
+			if len(c.Points) > 0 {
+				cpu = append(cpu, c)
+			}
+			memory = append(memory, m)
+		}
+	}
+	return cpu, memory
+}
+
+// timeChart creates a chart with one line per series. The series are sampled
+// at different times, so every row has a value for one series only and the
+// chart connects the points of each series across the gaps. The chart is empty
+// if there are no series.
+func timeChart(id string, series []resourceSeries, opts DygraphsOpts) (template.HTML, error) {
+	if len(series) == 0 {
+		return "", nil
+	}
+	labels := []string{"Seconds"}
+	var rows [][]interface{}
+	for i, s := range series {
+		labels = append(labels, s.Label)
+		for _, p := range s.Points {
+			row := make([]interface{}, len(series)+1)
+			row[0] = p[0]
+			row[i+1] = p[1]
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i][0].(float64) < rows[j][0].(float64) })
+
+	b, err := json.Marshal(rows)
+	if err != nil {
+		return "", err
+	}
+	opts.Labels = labels
+	opts.XLabel = "Seconds since start of test"
+	opts.Legend = "always"
+	opts.StrokeWidth = 1.3
+	opts.Width = 1500
+	opts.ConnectSeparatedPoints = true
+	return GenerateChart(id, b, opts)
+}
+
+// ContainerIO is the network and block I/O of a container during the test and
+// its memory working set at the end of the test, averaged over all repetitions
+// of a run.
+type ContainerIO struct {
+	Name      string // name of the run
+	RPS       uint16 // request rate of the run, only set for sweeps
+	Container string // like "app"
+
+	RxBytes, RxPackets, TxBytes, TxPackets IOValue
+	BlockRead, BlockWrite                  IOValue
+	WorkingSet                             IOValue
+}
+
+// IOValue is a metric of a container and its difference to the same container
+// of the baseline run at the same request rate, in percent. Diff is nil for
+// the baseline itself.
+type IOValue struct {
+	Value float64
+	Diff  *float64
+}
+
+// Bytes formats v as a byte count.
+func (v IOValue) Bytes() string {
+	return byteCountSI(int64(math.Round(v.Value))) + v.diff()
+}
+
+// Count formats v as a number, like a packet count.
+func (v IOValue) Count() string {
+	return fmt.Sprintf("%.0f", v.Value) + v.diff()
+}
+
+func (v IOValue) diff() string {
+	if v.Diff == nil {
+		return ""
+	}
+	return fmt.Sprintf(" (%+.2f%%)", *v.Diff)
+}
+
+// getContainerIO returns the I/O of the app, database and relay containers of
+// every run, compared to the baseline. It returns nil if no container reported
+// I/O, like in results of older versions of loadgen.
+func getContainerIO(groups []*runGroup) []ContainerIO {
+	var ios []ContainerIO
+	var found bool
+	type key struct {
+		RPS       uint16
+		Container string
+	}
+	baseline := make(map[key]ContainerIO)
+	for _, g := range groups {
+		for _, name := range resourceContainers {
+			var sum [7]float64
+			var n int
+			for _, tr := range g.TestResults {
+				stats, ok := tr.Stats[name]
+				if !ok {
+					continue
+				}
+				d := stats.Difference
+				for i, v := range []float64{
+					float64(d.NetworkRxBytes), float64(d.NetworkRxPackets),
+					float64(d.NetworkTxBytes), float64(d.NetworkTxPackets),
+					float64(d.BlockReadBytes), float64(d.BlockWriteBytes),
+					float64(stats.After.MemoryWorkingSetBytes),
+				} {
+					sum[i] += v
+					found = found || v != 0
+				}
+				n++
+			}
+			if n == 0 {
+				continue
+			}
+			io := ContainerIO{Name: g.Name, RPS: g.RPS, Container: name}
+			values := []*IOValue{
+				&io.RxBytes, &io.RxPackets, &io.TxBytes, &io.TxPackets,
+				&io.BlockRead, &io.BlockWrite, &io.WorkingSet,
+			}
+			for i, v := range values {
+				v.Value = sum[i] / float64(n)
+			}
+			k := key{g.RPS, name}
+			if g.Name == "baseline" {
+				baseline[k] = io
+			} else if b, ok := baseline[k]; ok {
+				bValues := []IOValue{
+					b.RxBytes, b.RxPackets, b.TxBytes, b.TxPackets,
+					b.BlockRead, b.BlockWrite, b.WorkingSet,
+				}
+				for i, v := range values {
+					if bValues[i].Value > 0 {
+						diff := percentDiffFloat(bValues[i].Value, v.Value)
+						v.Diff = &diff
+					}
+				}
+			}
+			ios = append(ios, io)
+		}
+	}
+	if !found {
+		return nil
+	}
+	return ios
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_resourceUsage(t *testing.T) {
+	start := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	sample := func(sec int, cpu, memory uint64) ContainerStats {
+		return ContainerStats{
+			Timestamp:        start.Add(time.Duration(sec) * time.Second),
+			CPUUsageTotal:    cpu,
+			MemoryUsageBytes: memory,
+		}
+	}
+	data := []ResultData{
+		{
+			Name: "baseline",
+			TestResult: TestResult{
+				Stats: map[string]Stats{
+					"app": {
+						Before: ContainerStats{Timestamp: start},
+						Samples: []ContainerStats{
+							sample(-1, 1e9, 10e6),
+							sample(0, 1.5e9, 12e6),
+							sample(2, 2.5e9, 20e6),
+						},
+					},
+					// Not charted.
+					"loadgen": {
+						Samples: []ContainerStats{sample(0, 0, 1e6), sample(1, 1e9, 1e6)},
+					},
+					// Without samples, as recorded by older versions of loadgen.
+					"postgres": {},
+				},
+			},
+		},
+	}
+	cpu, memory := resourceUsage(data)
+	wantCPU := []resourceSeries{
+		{Label: "baseline: app", Points: [][2]float64{{0, 50}, {2, 50}}},
+	}
+	wantMemory := []resourceSeries{
+		{Label: "baseline: app", Points: [][2]float64{{-1, 10}, {0, 12}, {2, 20}}},
+	}
+	if diff := cmp.Diff(wantCPU, cpu); diff != "" {
+		t.Errorf("CPU usage mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantMemory, memory); diff != "" {
+		t.Errorf("memory usage mismatch (-want +got):\n%s", diff)
+	}
+
+	if cpu, memory, err := resourceCharts(data[:0]); err != nil || cpu != "" || memory != "" {
+		t.Errorf("resourceCharts(nil) = %q, %q, %v, want empty charts", cpu, memory, err)
+	}
+}
+
+func Test_getContainerIO(t *testing.T) {
+	result := func(rx uint64, workingSet uint64) TestResult {
+		return TestResult{Stats: map[string]Stats{
+			"app": {
+				After:      ContainerStats{MemoryWorkingSetBytes: workingSet},
+				Difference: ContainerStatsDifference{NetworkRxBytes: int64(rx), NetworkTxBytes: 2 * int64(rx)},
+			},
+		}}
+	}
+	groups := []*runGroup{
+		{Name: "baseline", TestResults: []TestResult{result(100, 10e6), result(300, 30e6)}},
+		{Name: "instrumented", TestResults: []TestResult{result(300, 30e6)}},
+	}
+	got := getContainerIO(groups)
+	if len(got) != 2 {
+		t.Fatalf("got %d rows, want 2: %+v", len(got), got)
+	}
+	base, instr := got[0], got[1]
+	if base.Container != "app" || base.RxBytes.Value != 200 || base.TxBytes.Value != 400 || base.WorkingSet.Value != 20e6 || base.RxBytes.Diff != nil {
+		t.Errorf("got baseline %+v", base)
+	}
+	if instr.RxBytes.Diff == nil || *instr.RxBytes.Diff != 50 || instr.BlockRead.Diff != nil {
+		t.Errorf("got instrumented %+v, want 50%% more received bytes than baseline", instr)
+	}
+	if got, want := instr.WorkingSet.Bytes(), "30.0 MB (+50.00%)"; got != want {
+		t.Errorf("WorkingSet.Bytes() = %q, want %q", got, want)
+	}
+
+	var b bytes.Buffer
+	if err := reportTemplate.Execute(&b, ReportFile{ContainerIO: got}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "600 B") {
+		t.Errorf("report does not contain the sent bytes of the instrumented app")
+	}
+
+	// Results of older versions of loadgen have no I/O stats.
+	if got := getContainerIO([]*runGroup{{Name: "baseline", TestResults: []TestResult{{}}}}); got != nil {
+		t.Errorf("got %+v for results without I/O stats, want nil", got)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// eachResult calls fn with the result of every request of the test, in the
+// order loadgen received them. Results are read one at a time from the results
+// file written by loadgen, see readTestResult, such that they need not fit in
+// memory. Older versions of loadgen stored results in result.json instead.
+func (tr TestResult) eachResult(fn func(*vegeta.Result)) error {
+	if tr.Results == "" {
+		for _, r := range tr.LoadGenResult {
+			fn(r)
+		}
+		return nil
+	}
+	f, err := os.Open(tr.Results)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", tr.Results, err)
+	}
+	dec := vegeta.NewJSONDecoder(gz)
+	for {
+		var r vegeta.Result
+		if err := dec.Decode(&r); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", tr.Results, err)
+		}
+		fn(&r)
+	}
+}
+
+// resolveResults makes the path of the results file of tr, which is relative
+// to the directory of result.json, usable from the working directory.
+func (tr *TestResult) resolveResults(resultPath string) {
+	if tr.Results != "" && !filepath.IsAbs(tr.Results) {
+		tr.Results = filepath.Join(filepath.Dir(resultPath), tr.Results)
+	}
+}
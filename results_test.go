@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestEachResult(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string][]byte
+	}{
+		{
+			name:  "results file",
+			files: fakeResultFiles(t, 3),
+		},
+		{
+			name: "legacy",
+			files: map[string][]byte{
+				"result.json": []byte(`{"loadgen_result": [{"seq": 0}, {"seq": 1}, {"seq": 2}]}`),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, b := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), b, 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tr, err := readTestResult(filepath.Join(dir, "result.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var seqs []uint64
+			err = tr.eachResult(func(r *vegeta.Result) { seqs = append(seqs, r.Seq) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(seqs) != 3 || seqs[0] != 0 || seqs[2] != 2 {
+				t.Errorf("eachResult() read results %v, want [0 1 2]", seqs)
+			}
+		})
+	}
+
+	tr := TestResult{Results: filepath.Join(t.TempDir(), "results.jsonl.gz")}
+	if err := tr.eachResult(func(*vegeta.Result) {}); err == nil {
+		t.Errorf("eachResult() with missing results file succeeded, want error")
+	}
+}
@@ -2,27 +2,29 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
 	"strings"
 )
 
-// sanityCheck panics if r fails to match expectations.
-func sanityCheck(r []ResultData) {
+// sanityCheck returns an error if r fails to match expectations.
+func sanityCheck(r []ResultData) error {
 	if len(r) == 0 {
-		panic("no results")
+		return errors.New("no results")
 	}
-	var errors []error
+	var failures []error
 	for _, rr := range r {
 		for _, e := range sanityCheckOne(rr) {
-			errors = append(errors, e)
+			failures = append(failures, e)
 			log.Print(e)
 		}
 	}
-	if n := len(errors); n > 0 {
-		panic(fmt.Errorf("%d failures", n))
+	if n := len(failures); n > 0 {
+		return fmt.Errorf("sanity check: %d failures", n)
 	}
+	return nil
 }
 
 func sanityCheckOne(r ResultData) []error {
@@ -33,7 +35,7 @@ func sanityCheckOne(r ResultData) []error {
 
 	errors = append(errors, sanityCheckLoadGenerator(r)...)
 
-	switch r.Name {
+	switch r.App {
 	case "baseline":
 		errors = append(errors, sanityCheckFakeRelayBaseline(r)...)
 	case "instrumented":
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tfbConfig is the part of a benchmark_config.json file of the TechEmpower
+// Framework Benchmarks used to scaffold a platform.
+type tfbConfig struct {
+	Framework string `json:"framework"`
+	Tests     []map[string]struct {
+		UpdateURL string `json:"update_url"`
+		Database  string `json:"database"`
+		Port      int    `json:"port"`
+	} `json:"tests"`
+}
+
+// tfbTest is a test of a TFB framework that can be used as the baseline app.
+type tfbTest struct {
+	Name      string // like "default" or "postgresql"
+	UpdateURL string // like "/update?queries="
+	Port      int
+}
+
+// postgresTest returns the test of a TFB framework that uses PostgreSQL and
+// implements the Database Updates test. Tests are considered in lexical order
+// of their names, such that the result is deterministic.
+func (c tfbConfig) postgresTest() (tfbTest, error) {
+	for _, tests := range c.Tests {
+		names := make([]string, 0, len(tests))
+		for name := range tests {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			t := tests[name]
+			if !strings.EqualFold(t.Database, "postgres") || t.UpdateURL == "" {
+				continue
+			}
+			return tfbTest{Name: name, UpdateURL: t.UpdateURL, Port: t.Port}, nil
+		}
+	}
+	return tfbTest{}, fmt.Errorf("no test with a Postgres database and an update URL")
+}
+
+// dockerfile returns the name of the Dockerfile of the test in the framework
+// directory dir. TFB usually names it after the framework and the test, like
+// "django-postgresql.dockerfile", omitting the name of the default test, but
+// some frameworks use a different prefix.
+func (t tfbTest) dockerfile(dir, framework string) (string, error) {
+	name := framework + "-" + t.Name + ".dockerfile"
+	if t.Name == "default" {
+		name = framework + ".dockerfile"
+	}
+	if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+		return name, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*-"+t.Name+".dockerfile"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) != 1 {
+		return "", fmt.Errorf("test %q: cannot find Dockerfile %s in %s", t.Name, name, dir)
+	}
+	return filepath.Base(matches[0]), nil
+}
+
+// targetPath returns the path of the Database Updates test with 10 queries.
+// TFB configures the URL without the number of queries, like
+// "/update?queries=" or "/updates/".
+func (t tfbTest) targetPath() string {
+	if strings.HasSuffix(t.UpdateURL, "=") || strings.HasSuffix(t.UpdateURL, "/") {
+		return t.UpdateURL + "10"
+	}
+	return t.UpdateURL
+}
+
+// tfbFilesToRemove are files of TFB frameworks that are not needed to run an
+// app.
+var tfbFilesToRemove = []string{"README.md", "benchmark_config.json", "config.toml"}
+
+// Scaffold creates a new platform from the framework of a local checkout of the
+// TechEmpower Framework Benchmarks at tfbPath, automating the steps documented
+// in platform/README.md. It copies the framework to
+// platform/LANGUAGE/FRAMEWORK/baseline, keeping only the Dockerfile of the
+// Postgres test, writes a config.json targeting the Database Updates test and
+// copies the baseline app to instrumented. Language and framework are matched
+// case-insensitively against the directories of the checkout.
+//
+// Scaffold returns the path of the new platform. It does not change existing
+// platforms, and removes the new platform if it fails, such that it can be
+// retried.
+func Scaffold(w io.Writer, tfbPath, language, framework string) (_ string, err error) {
+	langDir, err := findDirFold(filepath.Join(tfbPath, "frameworks"), language)
+	if err != nil {
+		return "", err
+	}
+	src, err := findDirFold(langDir, framework)
+	if err != nil {
+		return "", err
+	}
+	framework = filepath.Base(src)
+
+	b, err := os.ReadFile(filepath.Join(src, "benchmark_config.json"))
+	if err != nil {
+		return "", err
+	}
+	var tc tfbConfig
+	if err := json.Unmarshal(b, &tc); err != nil {
+		return "", fmt.Errorf("%s: %w", filepath.Join(src, "benchmark_config.json"), err)
+	}
+	test, err := tc.postgresTest()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", src, err)
+	}
+	prefix := tc.Framework
+	if prefix == "" {
+		prefix = framework
+	}
+	dockerfile, err := test.dockerfile(src, prefix)
+	if err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join("platform", strings.ToLower(filepath.Base(langDir)), strings.ToLower(framework))
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf("%s already exists", dst)
+	}
+	_, langErr := os.Stat(filepath.Dir(dst))
+	defer func() {
+		if err == nil {
+			return
+		}
+		os.RemoveAll(dst)
+		if langErr != nil {
+			// Remove the directory of a new language, if empty.
+			os.Remove(filepath.Dir(dst))
+		}
+	}()
+
+	baseline := filepath.Join(dst, "baseline")
+	err = copyDir(src, baseline, func(rel string, d fs.DirEntry) bool {
+		if d.IsDir() || filepath.Dir(rel) != "." {
+			return false
+		}
+		for _, name := range tfbFilesToRemove {
+			if rel == name {
+				return true
+			}
+		}
+		return strings.HasSuffix(rel, ".dockerfile") && rel != dockerfile
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(w, "Copied %s to %s, using %s\n", src, baseline, dockerfile)
+
+	var config struct {
+		Target struct {
+			Path string `json:"path"`
+		} `json:"target"`
+		RPS      uint16 `json:"rps"`
+		Duration string `json:"duration"`
+	}
+	config.Target.Path = test.targetPath()
+	config.RPS = 10
+	config.Duration = "30s"
+	b, err = json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	configPath := filepath.Join(dst, "config.json")
+	if err := os.WriteFile(configPath, append(b, '\n'), 0666); err != nil {
+		return "", err
+	}
+	if _, err := ReadPlatformConfig(configPath); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(w, "Wrote %s targeting %s\n", configPath, config.Target.Path)
+	if test.Port != 0 && test.Port != 8080 {
+		fmt.Fprintf(w, "Warning: the app listens on port %d, the load generator expects port 8080\n", test.Port)
+	}
+
+	instrumented := filepath.Join(dst, "instrumented")
+	if err := copyDir(baseline, instrumented, nil); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(w, "Copied %s to %s, add Sentry instrumentation there\n", baseline, instrumented)
+	return dst, nil
+}
+
+// findDirFold returns the subdirectory of dir whose name equals name under
+// Unicode case-folding.
+func findDirFold(dir, name string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.IsDir() && strings.EqualFold(e.Name(), name) {
+			return filepath.Join(dir, e.Name()), nil
+		}
+	}
+	return "", fmt.Errorf("no directory %q in %s", name, dir)
+}
+
+// copyDir copies the directory tree src to dst, preserving file modes and
+// symbolic links. Files and directories for which skip returns true are not
+// copied. Skip receives paths relative to src and may be nil.
+func copyDir(src, dst string, skip func(rel string, d fs.DirEntry) bool) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && skip != nil && skip(rel, d) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// copyFile copies the regular file src to dst, creating dst with mode perm.
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScaffold(t *testing.T) {
+	chdirPlatform(t, "baseline")
+	tfb := t.TempDir()
+	src := filepath.Join(tfb, "frameworks", "Python", "Flask")
+	files := map[string]string{
+		"benchmark_config.json": `{
+  "framework": "flask",
+  "tests": [{
+    "default": {"update_url": "/updates?queries=", "database": "MySQL", "port": 8080},
+    "postgresql": {"update_url": "/updates?queries=", "database": "Postgres", "port": 8080},
+    "raw": {"db_url": "/db", "database": "Postgres", "port": 8080}
+  }]
+}`,
+		"README.md":                             "# Flask",
+		"config.toml":                           "",
+		"flask.dockerfile":                      "FROM python",
+		"flask-postgresql.dockerfile":           "FROM python",
+		"flask-raw.dockerfile":                  "FROM python",
+		"app.py":                                "print('hello')",
+		filepath.Join("templates", "README.md"): "kept, not at the top level",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst, err := Scaffold(io.Discard, tfb, "python", "flask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("platform", "python", "flask"); dst != want {
+		t.Errorf("got platform %q, want %q", dst, want)
+	}
+	for _, app := range []string{"baseline", "instrumented"} {
+		var got []string
+		err := filepath.WalkDir(filepath.Join(dst, app), func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(filepath.Join(dst, app), path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"app.py", "flask-postgresql.dockerfile", "templates/README.md"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s files (-want +got):\n%s", app, diff)
+		}
+	}
+	pc, err := ReadPlatformConfig(filepath.Join(dst, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pc.TargetPath(), "/updates?queries=10"; got != want {
+		t.Errorf("got target %q, want %q", got, want)
+	}
+
+	if _, err := Scaffold(io.Discard, tfb, "python", "flask"); err == nil {
+		t.Error("scaffolding an existing platform: got nil error")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// RunStatus is the outcome of a run.
+type RunStatus string
+
+const (
+	StatusOK            RunStatus = "ok"
+	StatusFailed        RunStatus = "failed"         // setup failed, e.g. invalid configuration
+	StatusBuildFailed   RunStatus = "build-failed"   // building Docker images failed
+	StatusNotReady      RunStatus = "not-ready"      // app did not become ready to receive traffic
+	StatusLoadGenFailed RunStatus = "loadgen-failed" // load generator failed or produced no result
+	StatusCanceled      RunStatus = "canceled"       // interrupted by the user
+	StatusTimedOut      RunStatus = "timed-out"      // exceeded the run or session timeout, results may be partial
+)
+
+// RunError is the error that caused a run to fail.
+type RunError struct {
+	Status RunStatus
+	Err    error
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Status, e.Err)
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// runError returns a RunError with the given status, unless err is already a
+// RunError.
+func runError(status RunStatus, err error) error {
+	var re *RunError
+	if errors.As(err, &re) {
+		return err
+	}
+	return &RunError{Status: status, Err: err}
+}
+
+// statusOf returns the status of a run that failed with err.
+func statusOf(err error) RunStatus {
+	if err == nil {
+		return StatusOK
+	}
+	var re *RunError
+	if errors.As(err, &re) {
+		return re.Status
+	}
+	return StatusFailed
+}
+
+// statusFile is the content of status.json, written to the result directory of
+// every run.
+type statusFile struct {
+	Name     string        `json:"name"`
+	Status   RunStatus     `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Project  string        `json:"project,omitempty"` // compose project left running with -keep
+}
+
+// writeStatus writes status.json to the result directory of r.
+func writeStatus(r *RunResult, d time.Duration) error {
+	s := statusFile{
+		Name:     r.Name,
+		Status:   statusOf(r.Err),
+		Duration: d,
+		Project:  r.Project,
+	}
+	if r.Err != nil {
+		s.Error = r.Err.Error()
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.Path, 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.Path, "status.json"), b, 0666)
+}
+
+// summaryRow is a row of the summary table printed at the end of a session.
+type summaryRow struct {
+	Platform string
+	Run      string // empty for errors that affect the whole platform
+	Status   RunStatus
+	Err      error
+}
+
+// printSummary writes a table with the outcome of all runs to w and reports
+// whether all of them succeeded.
+func printSummary(w io.Writer, rows []summaryRow) bool {
+	ok := true
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PLATFORM\tRUN\tSTATUS\tERROR")
+	for _, r := range rows {
+		var msg string
+		if r.Err != nil {
+			ok = false
+			msg = r.Err.Error()
+			var re *RunError
+			if errors.As(r.Err, &re) {
+				msg = re.Err.Error()
+			}
+		}
+		run := r.Run
+		if run == "" {
+			run = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Platform, run, r.Status, msg)
+	}
+	tw.Flush()
+	return ok
+}
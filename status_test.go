@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestStatusOf(t *testing.T) {
+	tests := []struct {
+		Err  error
+		Want RunStatus
+	}{
+		{nil, StatusOK},
+		{errors.New("boom"), StatusFailed},
+		{runError(StatusBuildFailed, errors.New("boom")), StatusBuildFailed},
+		// wrapped errors keep their status
+		{fmt.Errorf("run: %w", runError(StatusNotReady, errors.New("boom"))), StatusNotReady},
+		// the innermost status wins
+		{runError(StatusLoadGenFailed, runError(StatusCanceled, errors.New("boom"))), StatusCanceled},
+	}
+	for _, tt := range tests {
+		if got := statusOf(tt.Err); got != tt.Want {
+			t.Errorf("statusOf(%v) = %q, want %q", tt.Err, got, tt.Want)
+		}
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	ok := []summaryRow{
+		{Platform: "platform/python/django", Run: "baseline", Status: StatusOK},
+		{Platform: "platform/python/django", Run: "instrumented", Status: StatusOK},
+	}
+	if !printSummary(io.Discard, ok) {
+		t.Errorf("printSummary reported failure for successful runs")
+	}
+	failed := append(ok, summaryRow{
+		Platform: "platform/python/django",
+		Run:      "opentelemetry",
+		Status:   StatusBuildFailed,
+		Err:      runError(StatusBuildFailed, errors.New("exit status 1")),
+	})
+	if printSummary(io.Discard, failed) {
+		t.Errorf("printSummary reported success for failed runs")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"sort"
+)
+
+// sweepCharts creates charts of latency and CPU usage versus offered load
+// for benchmarks that ran apps at more than one request rate.
+func sweepCharts(groups []*runGroup) (latency, cpu template.HTML, err error) {
+	var names []string
+	var rates []uint16
+	byRate := make(map[uint16]map[string]*runGroup)
+	for _, g := range groups {
+		if _, ok := byRate[g.RPS]; !ok {
+			byRate[g.RPS] = make(map[string]*runGroup)
+			rates = append(rates, g.RPS)
+		}
+		if !contains(names, g.Name) {
+			names = append(names, g.Name)
+		}
+		byRate[g.RPS][g.Name] = g
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i] < rates[j] })
+
+	// Missing values are encoded as null, such that Dygraph leaves gaps
+	// in the series.
+	var latencyData, cpuData [][]interface{}
+	latencyLabels := []string{"RPS"}
+	cpuLabels := []string{"RPS"}
+	for _, name := range names {
+		latencyLabels = append(latencyLabels, name+": p50", name+": p99")
+		cpuLabels = append(cpuLabels, name)
+	}
+	for _, rps := range rates {
+		latencyRow := []interface{}{rps}
+		cpuRow := []interface{}{rps}
+		for _, name := range names {
+			g, ok := byRate[rps][name]
+			if !ok {
+				latencyRow = append(latencyRow, nil, nil)
+				cpuRow = append(cpuRow, nil)
+				continue
+			}
+			latencyRow = append(latencyRow,
+				float64(g.Latencies.P50.Microseconds())/1000,
+				float64(g.Latencies.P99.Microseconds())/1000,
+			)
+			if usage, ok := meanAppCPUUsage(g.TestResults); ok {
+				cpuRow = append(cpuRow, usage)
+			} else {
+				cpuRow = append(cpuRow, nil)
+			}
+		}
+		latencyData = append(latencyData, latencyRow)
+		cpuData = append(cpuData, cpuRow)
+	}
+
+	b, err := json.Marshal(latencyData)
+	if err != nil {
+		return "", "", err
+	}
+	latency, err = GenerateChart(
+		"sweepLatencyPlot",
+		b,
+		DygraphsOpts{
+			Title:       "Latency vs Offered Load",
+			Labels:      latencyLabels,
+			YLabel:      "Latency (ms)",
+			XLabel:      "Requests per second",
+			Legend:      "always",
+			StrokeWidth: 1.3,
+			Width:       1500,
+		},
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	b, err = json.Marshal(cpuData)
+	if err != nil {
+		return "", "", err
+	}
+	cpu, err = GenerateChart(
+		"sweepCPUPlot",
+		b,
+		DygraphsOpts{
+			Title:       "App CPU Usage vs Offered Load",
+			Labels:      cpuLabels,
+			YLabel:      "CPU usage (%)",
+			XLabel:      "Requests per second",
+			Legend:      "always",
+			StrokeWidth: 1.3,
+			Width:       1500,
+		},
+	)
+	if err != nil {
+		return "", "", err
+	}
+	return latency, cpu, nil
+}
+
+// meanAppCPUUsage returns the mean CPU usage of the app container during the
+// test, in percent of one CPU core.
+func meanAppCPUUsage(trs []TestResult) (float64, bool) {
+	var sum float64
+	var n int
+	for _, tr := range trs {
+		stats, ok := tr.Stats["app"]
+		if !ok || stats.Difference.Duration <= 0 {
+			continue
+		}
+		sum += float64(stats.Difference.CPUUsageTotal) / float64(stats.Difference.Duration) * 100
+		n++
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func Test_meanAppCPUUsage(t *testing.T) {
+	result := func(cpu time.Duration, duration time.Duration) TestResult {
+		return TestResult{Stats: map[string]Stats{
+			"app": {Difference: ContainerStatsDifference{Duration: duration, CPUUsageTotal: int64(cpu)}},
+		}}
+	}
+	tests := []struct {
+		name   string
+		trs    []TestResult
+		want   float64
+		wantOK bool
+	}{
+		{name: "no results"},
+		{name: "no stats", trs: []TestResult{{}}},
+		{name: "zero duration", trs: []TestResult{result(time.Second, 0)}},
+		{
+			name:   "one core",
+			trs:    []TestResult{result(10*time.Second, 10*time.Second)},
+			want:   100,
+			wantOK: true,
+		},
+		{
+			name:   "mean over results with stats",
+			trs:    []TestResult{result(5*time.Second, 10*time.Second), {}, result(2*time.Second, 20*time.Second)},
+			want:   30,
+			wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := meanAppCPUUsage(tt.trs)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("meanAppCPUUsage() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func Test_sweepCharts(t *testing.T) {
+	group := func(name string, rps uint16, p50 time.Duration, cpu time.Duration) *runGroup {
+		g := &runGroup{Name: name, RPS: rps, Latencies: vegeta.LatencyMetrics{P50: p50, P99: 2 * p50}}
+		g.TestResults = []TestResult{{Stats: map[string]Stats{
+			"app": {Difference: ContainerStatsDifference{Duration: 10 * time.Second, CPUUsageTotal: int64(cpu)}},
+		}}}
+		return g
+	}
+	groups := []*runGroup{
+		group("baseline", 10, time.Millisecond, time.Second),
+		group("instrumented", 10, 2*time.Millisecond, 2*time.Second),
+		group("baseline", 20, 3*time.Millisecond, 3*time.Second),
+	}
+	latency, cpu, err := sweepCharts(groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rows are ordered by rate, and the instrumented app, which did not
+	// run at 20 rps, has gaps.
+	for _, want := range []string{
+		`[[10,1,2,2,4],[20,3,6,null,null]]`,
+		`"labels":["RPS","baseline: p50","baseline: p99","instrumented: p50","instrumented: p99"]`,
+	} {
+		if !strings.Contains(string(latency), want) {
+			t.Errorf("latency chart does not contain %s:\n%s", want, latency)
+		}
+	}
+	for _, want := range []string{
+		`[[10,10,20],[20,30,null]]`,
+		`"labels":["RPS","baseline","instrumented"]`,
+	} {
+		if !strings.Contains(string(cpu), want) {
+			t.Errorf("CPU chart does not contain %s:\n%s", want, cpu)
+		}
+	}
+}
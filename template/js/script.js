@@ -14,13 +14,17 @@ const TICKS = [
 
 const CLASSNAMES = Object.freeze({
   JSON_FORMAT: "jsonFormat",
-  HDR: "hdr"
+  HDR: "hdr",
+  HDR_ENDPOINT: "hdrEndpoint"
 });
 
 document.addEventListener("DOMContentLoaded", init);
 
 function init() {
-  drawChart();
+  drawChart(CLASSNAMES.HDR, 'percentileLatency', 'Latency by Percentile Distribution');
+  if (document.getElementById('percentileLatencyByEndpoint')) {
+    drawChart(CLASSNAMES.HDR_ENDPOINT, 'percentileLatencyByEndpoint', 'Latency by Percentile Distribution per Endpoint');
+  }
 }
 
 function getChartData(names, histos) {
@@ -36,10 +40,10 @@ function getChartData(names, histos) {
   return series
 }
 
-function drawChart() {
+function drawChart(className, elementId, title) {
   const histos = [];
   const names = [];
-  const hdrNodes = document.querySelectorAll(getClassName(CLASSNAMES.HDR));
+  const hdrNodes = document.querySelectorAll(getClassName(className));
   hdrNodes.forEach((node) => {
     // name
     names.push(node.getAttribute("data-name"));
@@ -49,8 +53,8 @@ function drawChart() {
 
   const [_, ...data] = getChartData(names, histos);
 
-  new Dygraph(document.getElementById('percentileLatency'), data, {
-    title: 'Latency by Percentile Distribution',
+  new Dygraph(document.getElementById(elementId), data, {
+    title: title,
     ylabel: 'Latency (ms)',
     xlabel: 'Percentile',
     legend: 'always',
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// SLO is a service level objective the target must meet at a given rate for
+// the rate to be considered sustainable.
+type SLO struct {
+	Success float64       // minimum ratio of successful requests
+	P99     time.Duration // maximum 99th percentile latency
+}
+
+// CapacityResult is the outcome of a search for the maximum sustainable
+// throughput of the target.
+type CapacityResult struct {
+	// RPS is the highest rate at which the target met the SLO, or zero if
+	// the target did not meet the SLO at any rate.
+	RPS   uint           `json:"rps"`
+	Steps []CapacityStep `json:"steps"`
+}
+
+// CapacityStep is a single measurement taken during a capacity search.
+type CapacityStep struct {
+	RPS        uint          `json:"rps"`
+	Success    float64       `json:"success"`
+	P99        time.Duration `json:"99th"`
+	Throughput float64       `json:"throughput"`
+	OK         bool          `json:"ok"`
+}
+
+// searchCapacity searches for the highest rate at which the target meets the
+// SLO. It ramps up the rate exponentially starting at start until the SLO is
+// violated or max is reached, then narrows down the result with a binary
+// search. Each rate is tested for the given step duration, requesting the
+// targets of tr.
+func searchCapacity(tr vegeta.Targeter, start, max uint, step time.Duration, slo SLO) CapacityResult {
+	log.Printf("Searching capacity of target (SLO: success >= %v, p99 <= %v)", slo.Success, slo.P99)
+
+	var result CapacityResult
+	probe := func(rps uint) bool {
+		m := attack(tr, vegeta.Rate{Freq: int(rps), Per: time.Second}, step, nil).Metrics
+		s := CapacityStep{
+			RPS:        rps,
+			Success:    m.Success,
+			P99:        m.Latencies.P99,
+			Throughput: m.Throughput,
+			OK:         m.Success >= slo.Success && m.Latencies.P99 <= slo.P99,
+		}
+		log.Printf("Capacity search: %d rps: success %v, p99 %v, ok: %v", s.RPS, s.Success, s.P99, s.OK)
+		result.Steps = append(result.Steps, s)
+		return s.OK
+	}
+
+	if start == 0 {
+		start = 1
+	}
+	// lo is the highest rate known to meet the SLO, hi is the lowest rate
+	// known to violate it.
+	var lo, hi uint
+	for rps := start; ; rps *= 2 {
+		if rps > max {
+			rps = max
+		}
+		if !probe(rps) {
+			hi = rps
+			break
+		}
+		lo = rps
+		if rps == max {
+			log.Printf("Capacity search: reached max rate of %d rps", max)
+			result.RPS = lo
+			return result
+		}
+	}
+	// Stop when the search interval is within 5% of the lower bound.
+	for hi-lo > 1 && hi-lo > lo/20 {
+		mid := lo + (hi-lo)/2
+		if probe(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	result.RPS = lo
+	log.Printf("Capacity of target: %d rps", result.RPS)
+	return result
+}
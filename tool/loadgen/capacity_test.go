@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rateLimitedServer returns a server that fails requests that arrive sooner
+// than 1/limit seconds after the previous request, such that the target
+// violates an SLO on the success ratio above about limit requests per second.
+func rateLimitedServer(t *testing.T, limit float64) *httptest.Server {
+	t.Helper()
+	minInterval := time.Duration(float64(time.Second) / limit)
+	var mu sync.Mutex
+	var last time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		now := time.Now()
+		tooSoon := now.Sub(last) < minInterval
+		last = now
+		mu.Unlock()
+		if tooSoon {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSearchCapacity(t *testing.T) {
+	// Probes double from 15 rps until 120 rps, which violates the SLO.
+	// The limit of 85 rps is far from the probes of the doubling phase,
+	// such that jitter in the request timing does not change their
+	// outcome.
+	srv := rateLimitedServer(t, 85)
+	slo := SLO{Success: 0.75, P99: time.Second}
+	got := searchCapacity(staticTargeter(srv.URL), 15, 1000, 500*time.Millisecond, slo)
+
+	if len(got.Steps) < 5 {
+		t.Fatalf("got %d steps, want doubling and bisection: %+v", len(got.Steps), got.Steps)
+	}
+	for i, want := range []CapacityStep{{RPS: 15, OK: true}, {RPS: 30, OK: true}, {RPS: 60, OK: true}, {RPS: 120}} {
+		if s := got.Steps[i]; s.RPS != want.RPS || s.OK != want.OK {
+			t.Errorf("step %d: got %d rps, ok: %v, want %d rps, ok: %v", i, s.RPS, s.OK, want.RPS, want.OK)
+		}
+	}
+	// Every step of the bisection probes a rate between the highest rate
+	// known to meet the SLO and the lowest rate known to violate it.
+	lo, hi := uint(60), uint(120)
+	for _, s := range got.Steps[4:] {
+		if s.RPS <= lo || s.RPS >= hi {
+			t.Fatalf("bisection probed %d rps outside of (%d, %d)", s.RPS, lo, hi)
+		}
+		if s.OK {
+			lo = s.RPS
+		} else {
+			hi = s.RPS
+		}
+	}
+	if got.RPS != lo {
+		t.Errorf("RPS = %d, want %d, the highest rate that met the SLO", got.RPS, lo)
+	}
+	if hi-lo > 1 && hi-lo > lo/20 {
+		t.Errorf("search stopped with interval (%d, %d), want within 5%%", lo, hi)
+	}
+}
+
+func TestSearchCapacityMax(t *testing.T) {
+	srv := rateLimitedServer(t, 1000)
+	got := searchCapacity(staticTargeter(srv.URL), 0, 25, 200*time.Millisecond, SLO{Success: 0.75, P99: time.Second})
+	if got.RPS != 25 {
+		t.Errorf("RPS = %d, want max rate of 25", got.RPS)
+	}
+	var rates []uint
+	for _, s := range got.Steps {
+		rates = append(rates, s.RPS)
+	}
+	// A start of 0 begins at 1 rps, and the last probe is clamped to max.
+	if want := []uint{1, 2, 4, 8, 16, 25}; !reflect.DeepEqual(rates, want) {
+		t.Errorf("probed rates %v, want %v", rates, want)
+	}
+}
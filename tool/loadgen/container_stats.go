@@ -2,56 +2,143 @@ package main
 
 import (
 	"log"
+	"sync"
 	"time"
-
-	cadvisor "github.com/google/cadvisor/client/v2"
-	cadvisor_info "github.com/google/cadvisor/info/v2"
 )
 
 type Stats struct {
 	Before     ContainerStats           `json:"before"`
 	After      ContainerStats           `json:"after"`
 	Difference ContainerStatsDifference `json:"difference"`
+	Samples    []ContainerStats         `json:"samples,omitempty"` // sampled during warmup and test
 }
 
 type ContainerStats struct {
 	Timestamp           time.Time `json:"timestamp"`
+	MemoryUsageBytes    uint64    `json:"memory_usage_bytes"`
 	MemoryMaxUsageBytes uint64    `json:"memory_max_usage_bytes"`
-	CPUUsageUser        uint64    `json:"cpu_usage_user"`
-	CPUUsageSystem      uint64    `json:"cpu_usage_system"`
-	CPUUsageTotal       uint64    `json:"cpu_usage_total"`
+	// MemoryWorkingSetBytes is the memory usage without inactive file
+	// cache, which the kernel can reclaim.
+	MemoryWorkingSetBytes uint64 `json:"memory_working_set_bytes"`
+	CPUUsageUser          uint64 `json:"cpu_usage_user"`
+	CPUUsageSystem        uint64 `json:"cpu_usage_system"`
+	CPUUsageTotal         uint64 `json:"cpu_usage_total"`
+	// Network counters exclude the loopback interface.
+	NetworkRxBytes   uint64 `json:"network_rx_bytes"`
+	NetworkRxPackets uint64 `json:"network_rx_packets"`
+	NetworkTxBytes   uint64 `json:"network_tx_bytes"`
+	NetworkTxPackets uint64 `json:"network_tx_packets"`
+	BlockReadBytes   uint64 `json:"block_read_bytes"`
+	BlockWriteBytes  uint64 `json:"block_write_bytes"`
 }
 
 type ContainerStatsDifference struct {
-	Duration            time.Duration `json:"duration"`
-	MemoryMaxUsageBytes int64         `json:"memory_max_usage_bytes"`
-	CPUUsageUser        int64         `json:"cpu_usage_user"`
-	CPUUsageSystem      int64         `json:"cpu_usage_system"`
-	CPUUsageTotal       int64         `json:"cpu_usage_total"`
+	Duration              time.Duration `json:"duration"`
+	MemoryMaxUsageBytes   int64         `json:"memory_max_usage_bytes"`
+	MemoryWorkingSetBytes int64         `json:"memory_working_set_bytes"`
+	CPUUsageUser          int64         `json:"cpu_usage_user"`
+	CPUUsageSystem        int64         `json:"cpu_usage_system"`
+	CPUUsageTotal         int64         `json:"cpu_usage_total"`
+	NetworkRxBytes        int64         `json:"network_rx_bytes"`
+	NetworkRxPackets      int64         `json:"network_rx_packets"`
+	NetworkTxBytes        int64         `json:"network_tx_bytes"`
+	NetworkTxPackets      int64         `json:"network_tx_packets"`
+	BlockReadBytes        int64         `json:"block_read_bytes"`
+	BlockWriteBytes       int64         `json:"block_write_bytes"`
+}
+
+// difference returns the change of the stats of a container from before to
+// after.
+func difference(before, after ContainerStats) ContainerStatsDifference {
+	return ContainerStatsDifference{
+		Duration:              after.Timestamp.Sub(before.Timestamp),
+		MemoryMaxUsageBytes:   int64(after.MemoryMaxUsageBytes - before.MemoryMaxUsageBytes),
+		MemoryWorkingSetBytes: int64(after.MemoryWorkingSetBytes - before.MemoryWorkingSetBytes),
+		CPUUsageUser:          int64(after.CPUUsageUser - before.CPUUsageUser),
+		CPUUsageSystem:        int64(after.CPUUsageSystem - before.CPUUsageSystem),
+		CPUUsageTotal:         int64(after.CPUUsageTotal - before.CPUUsageTotal),
+		NetworkRxBytes:        int64(after.NetworkRxBytes - before.NetworkRxBytes),
+		NetworkRxPackets:      int64(after.NetworkRxPackets - before.NetworkRxPackets),
+		NetworkTxBytes:        int64(after.NetworkTxBytes - before.NetworkTxBytes),
+		NetworkTxPackets:      int64(after.NetworkTxPackets - before.NetworkTxPackets),
+		BlockReadBytes:        int64(after.BlockReadBytes - before.BlockReadBytes),
+		BlockWriteBytes:       int64(after.BlockWriteBytes - before.BlockWriteBytes),
+	}
 }
 
-func containerStats(cAdvisorURL string, containerName string) ContainerStats {
-	log.Printf("Fetching stats for container %q from %q", containerName, cAdvisorURL)
-	client, err := cadvisor.NewClient(cAdvisorURL)
+func containerStats(source StatsSource, containerName string) ContainerStats {
+	log.Printf("Fetching stats for container %q from %s", containerName, source)
+	s, err := source.Stats(containerName)
 	if err != nil {
 		panic(err)
 	}
-	opts := &cadvisor_info.RequestOptions{
-		IdType: cadvisor_info.TypeDocker,
-		Count:  1,
+	return s
+}
+
+// sampler periodically fetches the stats of containers, such that resource
+// usage can be plotted over time.
+type sampler struct {
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	samples map[string][]ContainerStats // by container name
+}
+
+// startSampling fetches the stats of the given containers every interval,
+// until Stop is called.
+func startSampling(source StatsSource, containers []string, interval time.Duration) *sampler {
+	log.Printf("Sampling stats of %d containers every %v", len(containers), interval)
+	s := &sampler{
+		quit:    make(chan struct{}),
+		samples: make(map[string][]ContainerStats),
 	}
-	m, err := client.Stats(containerName, opts)
-	if err != nil {
-		panic(err)
+	for _, name := range containers {
+		name := name
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			var errors int
+			for {
+				cs, err := source.Stats(name)
+				if err != nil {
+					// Log only the first error, a broken
+					// container would flood the output.
+					if errors == 0 {
+						log.Printf("Could not sample stats of container %q: %s", name, err)
+					}
+					errors++
+				} else {
+					s.add(name, cs)
+				}
+				select {
+				case <-t.C:
+				case <-s.quit:
+					return
+				}
+			}
+		}()
 	}
-	for _, v := range m {
-		return ContainerStats{
-			Timestamp:           v.Stats[0].Timestamp,
-			MemoryMaxUsageBytes: v.Stats[0].Memory.MaxUsage,
-			CPUUsageUser:        v.Stats[0].Cpu.Usage.User,
-			CPUUsageSystem:      v.Stats[0].Cpu.Usage.System,
-			CPUUsageTotal:       v.Stats[0].Cpu.Usage.Total,
-		}
+	return s
+}
+
+// add records a sample, skipping samples that were reported before, as
+// cAdvisor collects stats at its own pace.
+func (s *sampler) add(name string, cs ContainerStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	samples := s.samples[name]
+	if n := len(samples); n > 0 && !cs.Timestamp.After(samples[n-1].Timestamp) {
+		return
 	}
-	panic("missing cAdvisor stats")
+	s.samples[name] = append(samples, cs)
+}
+
+// Stop stops sampling and returns the samples by container name.
+func (s *sampler) Stop() map[string][]ContainerStats {
+	close(s.quit)
+	s.wg.Wait()
+	return s.samples
 }
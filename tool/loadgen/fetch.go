@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -15,26 +16,44 @@ import (
 
 type FetchResult struct {
 	Metrics       *vegeta.Metrics
+	Phases        PhaseMetrics // only set by Load.fetch
 	FirstResponse string
-	Res           []*vegeta.Result
 }
 
+// resultSink receives every result of an attack as it arrives, such that
+// results need not be held in memory.
+type resultSink func(*vegeta.Result)
+
 // fetch makes rps requests per second to fetch the given URL for the given
 // duration and returns metrics.
 func fetch(url string, rps uint, duration time.Duration, opts ...func(*vegeta.Attacker)) FetchResult {
-	target := vegeta.NewStaticTargeter(vegeta.Target{
-		Method: "GET",
-		URL:    url,
-	})
-	rate := vegeta.Rate{Freq: int(rps), Per: time.Second}
+	return attack(staticTargeter(url), vegeta.Rate{Freq: int(rps), Per: time.Second}, duration, nil, opts...)
+}
+
+// attack makes requests to the targets of tr at the rate determined by pacer
+// for the given duration and returns metrics. Results are passed to sink, if
+// not nil. The attack ends early when loadgen is stopped.
+func attack(tr vegeta.Targeter, pacer vegeta.Pacer, duration time.Duration, sink resultSink, opts ...func(*vegeta.Attacker)) FetchResult {
 	attacker := vegeta.NewAttacker(opts...)
-	ch := attacker.Attack(target, rate, duration, "")
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			attacker.Stop()
+		case <-done:
+		}
+	}()
+	return collect(attacker.Attack(tr, pacer, duration, ""), sink)
+}
 
+// collect reads results from ch until it is closed, passing them to sink if
+// not nil, and returns metrics.
+func collect(ch <-chan *vegeta.Result, sink resultSink) FetchResult {
 	var result FetchResult
 	var responseOnce sync.Once
 
 	var m vegeta.Metrics
-	var r []*vegeta.Result
 	for res := range ch {
 		responseOnce.Do(func() {
 			b, _ := httputil.DumpResponse(&http.Response{
@@ -48,14 +67,162 @@ func fetch(url string, rps uint, duration time.Duration, opts ...func(*vegeta.At
 			result.FirstResponse = string(b)
 		})
 		m.Add(res)
-		r = append(r, res)
+		if sink != nil {
+			sink(res)
+		}
 	}
 	m.Close()
 	result.Metrics = &m
-	result.Res = r
 	return result
 }
 
+// Load describes how to generate load against the target.
+//
+// In the open model, requests arrive at a fixed rate regardless of how long the
+// target takes to respond. In the closed model, a fixed number of virtual users
+// issue requests back-to-back, optionally pausing for a think time between
+// requests, such that a slower target receives fewer requests.
+//
+// In the open model, a profile may vary the request rate over time, starting
+// from RPS. See parseProfile for the supported profiles.
+type Load struct {
+	RPS         uint          // open model: requests per second
+	Profile     string        // open model: load profile, empty for a constant rate
+	Targets     []Target      // weighted endpoints, empty to request only the target URL
+	Concurrency uint          // closed model: number of virtual users
+	ThinkTime   time.Duration // closed model: pause between requests of a user
+}
+
+// Model returns the name of the load model, either "open" or "closed".
+func (l Load) Model() string {
+	if l.Concurrency > 0 {
+		return "closed"
+	}
+	return "open"
+}
+
+func (l Load) String() string {
+	if l.Concurrency > 0 {
+		return fmt.Sprintf("%d users, %v think time", l.Concurrency, l.ThinkTime)
+	}
+	if l.Profile != "" {
+		return fmt.Sprintf("%d rps, profile %s", l.RPS, l.Profile)
+	}
+	return fmt.Sprintf("%d rps", l.RPS)
+}
+
+// targeter returns a targeter for the load. Target paths are resolved
+// relative to url.
+func (l Load) targeter(url string) vegeta.Targeter {
+	if len(l.Targets) == 0 {
+		return staticTargeter(url)
+	}
+	return weightedTargeter(url, l.Targets)
+}
+
+// fetch generates load against the given URL for the given duration and
+// returns metrics, including the phases of requests. Results are passed to
+// sink, if not nil.
+func (l Load) fetch(url string, duration time.Duration, sink resultSink) FetchResult {
+	var phases phaseRecorder
+	var result FetchResult
+	tr := l.targeter(url)
+	switch {
+	case l.Concurrency > 0:
+		result = fetchClosed(tr, phases.client(int(l.Concurrency)), l.Concurrency, l.ThinkTime, duration, sink)
+	case l.Profile != "":
+		pacer, err := parseProfile(l.Profile, l.RPS, duration)
+		if err != nil {
+			panic(err)
+		}
+		result = attack(tr, pacer, duration, sink, vegeta.Client(phases.client(vegeta.DefaultConnections)))
+	default:
+		result = attack(tr, vegeta.Rate{Freq: int(l.RPS), Per: time.Second}, duration, sink, vegeta.Client(phases.client(vegeta.DefaultConnections)))
+	}
+	result.Phases = phases.Metrics()
+	return result
+}
+
+// fetchClosed makes requests to the targets of tr with client from n
+// concurrent virtual users for the given duration and returns metrics. Each user sends a request as
+// soon as it receives the response to its previous request and waits for the
+// think time. Users stop when loadgen is stopped. Results are passed to sink,
+// if not nil.
+func fetchClosed(tr vegeta.Targeter, client *http.Client, n uint, think, duration time.Duration, sink resultSink) FetchResult {
+	began := time.Now()
+	// Sequence numbers and timestamps are assigned together, such that
+	// timestamps increase monotonically with sequence numbers.
+	var mu sync.Mutex
+	var seq uint64
+	next := func() (uint64, time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		seq++
+		return seq - 1, time.Now()
+	}
+	ch := make(chan *vegeta.Result)
+	var wg sync.WaitGroup
+	for i := uint(0); i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for (duration <= 0 || time.Since(began) < duration) && !stopped() {
+				ch <- hit(client, tr, next)
+				if think > 0 {
+					time.Sleep(think)
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return collect(ch, sink)
+}
+
+// hit makes a single request to the next target of tr and returns its result,
+// mimicking the results of a vegeta.Attacker. The next function returns the
+// sequence number and timestamp of the request.
+func hit(client *http.Client, tr vegeta.Targeter, next func() (uint64, time.Time)) *vegeta.Result {
+	var res vegeta.Result
+	var tgt vegeta.Target
+	var err error
+	res.Seq, res.Timestamp = next()
+	defer func() {
+		res.Latency = time.Since(res.Timestamp)
+		if err != nil {
+			res.Error = err.Error()
+		}
+	}()
+	if err = tr(&tgt); err != nil {
+		return &res
+	}
+	res.Method, res.URL = tgt.Method, tgt.URL
+	req, err := tgt.Request()
+	if err != nil {
+		return &res
+	}
+	r, err := client.Do(req)
+	if err != nil {
+		return &res
+	}
+	defer r.Body.Close()
+	if res.Body, err = io.ReadAll(r.Body); err != nil {
+		return &res
+	}
+	res.BytesIn = uint64(len(res.Body))
+	if res.Code = uint16(r.StatusCode); res.Code < 200 || res.Code >= 400 {
+		res.Error = r.Status
+	}
+	res.Headers = r.Header
+	return &res
+}
+
+// errNotReady is the error when the target web app does not become ready to
+// receive traffic within the max wait time.
+var errNotReady = errors.New("target not ready")
+
 // waitUntilReady waits until the target web app is ready to receive traffic.
 func waitUntilReady(url string, maxWait time.Duration) {
 	if maxWait == 0 {
@@ -86,30 +253,36 @@ func waitUntilReady(url string, maxWait time.Duration) {
 		}
 		if time.Now().Add(sleep).After(deadline) {
 			_ = vegeta.NewTextReporter(metrics).Report(log.Writer())
-			panic(fmt.Errorf("target not ready after %v", time.Since(start)))
+			panic(fmt.Errorf("%w after %v", errNotReady, time.Since(start)))
 		}
 		log.Printf("Backing off for %v", sleep)
-		time.Sleep(sleep)
+		select {
+		case <-time.After(sleep):
+		case <-stop:
+			panic(fmt.Errorf("%w while waiting for target", errStopped))
+		}
 	}
 }
 
 // warmUp sends traffic to warm up the target web app, ensuring connectivity
 // with the database is established, caches are warm, any JIT has taken place,
-// etc.
-func warmUp(url string, rps uint, d time.Duration) {
+// etc. Any load profile is ignored, warming up at the base rate.
+func warmUp(url string, load Load, d time.Duration) {
 	if d <= 0 {
 		panic(fmt.Errorf("warmUp: nonpositive duration: %d", d))
 	}
-	log.Printf("Warming up target for %v", d)
-	fetch(url, rps, d)
+	load.Profile = ""
+	log.Printf("Warming up target for %v (%v)", d, load)
+	load.fetch(url, d, nil)
 }
 
-// test sends test traffic to the target web app and returns metrics.
-func test(url string, rps uint, d time.Duration) FetchResult {
+// test sends test traffic to the target web app and returns metrics. Results
+// are passed to sink, if not nil.
+func test(url string, load Load, d time.Duration, sink resultSink) FetchResult {
 	if d <= 0 {
-		log.Print("Testing target forever")
+		log.Printf("Testing target forever (%v)", load)
 	} else {
-		log.Printf("Testing target for %v", d)
+		log.Printf("Testing target for %v (%v)", d, load)
 	}
-	return fetch(url, rps, d)
+	return load.fetch(url, d, sink)
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchClosed(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	var arrivals []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	reset := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		inFlight, maxInFlight, arrivals = 0, 0, nil
+	}
+
+	t.Run("concurrency", func(t *testing.T) {
+		reset()
+		r := fetchClosed(staticTargeter(srv.URL), &http.Client{}, 3, 0, 300*time.Millisecond, nil)
+		if maxInFlight != 3 {
+			t.Errorf("max %d concurrent requests, want 3", maxInFlight)
+		}
+		if r.Metrics.Success != 1 {
+			t.Errorf("Success = %v, want 1", r.Metrics.Success)
+		}
+	})
+
+	t.Run("think time", func(t *testing.T) {
+		reset()
+		const think = 50 * time.Millisecond
+		r := fetchClosed(staticTargeter(srv.URL), &http.Client{}, 1, think, 300*time.Millisecond, nil)
+		// A single user waits for the response and the think time
+		// between requests.
+		if n := r.Metrics.Requests; n < 2 || n > 6 {
+			t.Errorf("made %d requests, want between 2 and 6", n)
+		}
+		for i := 1; i < len(arrivals); i++ {
+			if d := arrivals[i].Sub(arrivals[i-1]); d < think {
+				t.Errorf("request %d arrived %v after the previous, want at least %v", i, d, think)
+			}
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -9,8 +10,22 @@ import (
 	"runtime"
 	"strings"
 	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
+// usageError prints an error about the command line flags followed by the
+// usage, and exits.
+func usageError(format string, a ...interface{}) {
+	fmt.Fprintf(flag.CommandLine.Output(), format+"\n", a...)
+	flag.Usage()
+	os.Exit(1)
+}
+
+// exitNotReady is the exit code when the target does not become ready, such
+// that the runner can tell a broken app from a failure of loadgen itself.
+const exitNotReady = 3
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lmsgprefix)
 	log.SetPrefix("[loadgen] ")
@@ -21,87 +36,183 @@ func main() {
 			if !ok {
 				panic(err)
 			}
-			log.Fatalf("Failure: %s:%d: %s", file, line, err)
+			log.Printf("Failure: %s:%d: %s", file, line, err)
+			if err, ok := err.(error); ok && errors.Is(err, errNotReady) {
+				os.Exit(exitNotReady)
+			}
+			os.Exit(1)
 		}
 	}()
 
 	var options Options
 	flag.StringVar(&options.TargetURL, "target", "", "target `URL` (example \"http://app:8080/update?queries=10\") (required)")
+	flag.StringVar(&options.Targets, "targets", "", "JSON `file` with a weighted list of endpoints to request, with paths relative to -target")
+	flag.StringVar(&options.StatsSource, "stats", "cadvisor", "`source` of container stats, one of: "+strings.Join(StatsSources, ", "))
 	flag.StringVar(&options.CAdvisorURL, "cadvisor", "", "cAdvisor root `URL` (example \"http://cadvisor:8080\")")
+	flag.StringVar(&options.DockerSocket, "docker", "/var/run/docker.sock", "`path` of the Docker Engine API socket, for -stats docker and cgroup")
+	flag.StringVar(&options.CgroupRoot, "cgroup", "/sys/fs/cgroup", "`path` of the host's cgroup v2 hierarchy, for -stats cgroup")
+	flag.StringVar(&options.ProcRoot, "proc", "", "`path` of the host's proc file system, for network stats with -stats cgroup")
 	flag.StringVar(&options.FakerelayURL, "fakerelay", "", "fakerelay root `URL` (example \"http://relay:5000\")")
-	flag.StringVar(&options.Containers, "containers", "", "comma-separated list of container `names` to monitor with cAdvisor")
+	flag.StringVar(&options.Containers, "containers", "", "comma-separated list of container `names` to monitor")
 	flag.DurationVar(&options.MaxWait, "maxwait", 30*time.Second, "max wait until target is ready")
 	flag.DurationVar(&options.WarmupDuration, "warmup", 15*time.Second, "warmup duration")
 	flag.DurationVar(&options.TestDuration, "test", 30*time.Second, "test duration")
 	flag.UintVar(&options.RPS, "rps", 10, "requests per second")
+	flag.StringVar(&options.Profile, "profile", "", "vary the request rate during the test according to a load `profile` (example \"spike:peak=100,every=10s,length=2s\")")
+	flag.UintVar(&options.Concurrency, "concurrency", 0, "use a closed model with `n` virtual users instead of a fixed request rate")
+	flag.DurationVar(&options.ThinkTime, "think", 0, "think time between requests of a virtual user (closed model only)")
+	flag.BoolVar(&options.Capacity, "capacity", false, "search for the maximum sustainable throughput after the test")
+	flag.Float64Var(&options.SLOSuccess, "slo-success", 0.99, "minimum success `ratio` for the capacity search")
+	flag.DurationVar(&options.SLOP99, "slo-p99", 100*time.Millisecond, "maximum 99th percentile latency for the capacity search")
+	flag.DurationVar(&options.CapacityStep, "capacity-step", 10*time.Second, "duration of each step of the capacity search")
+	flag.UintVar(&options.CapacityMax, "capacity-max", 10000, "maximum requests per second for the capacity search")
+	flag.DurationVar(&options.SampleInterval, "sample", time.Second, "interval between samples of container stats during warmup and test, 0 to disable")
 	flag.StringVar(&options.Out, "out", filepath.Join(os.TempDir(), "loadgen", "result", time.Now().Format("20060102-150405")), "output path")
 	flag.Parse()
 
 	if options.TargetURL == "" {
-		fmt.Fprintln(flag.CommandLine.Output(), "flag -target is required")
-		flag.Usage()
-		os.Exit(1)
+		usageError("flag -target is required")
 	}
 
 	if options.CAdvisorURL != "" && options.Containers == "" {
-		panic("flag -containers is required when -cadvisor is provided")
+		usageError("flag -containers is required when -cadvisor is provided")
+	}
+	if options.Containers != "" && options.StatsSource == "cadvisor" && options.CAdvisorURL == "" {
+		usageError("flag -cadvisor is required when -containers is provided with -stats cadvisor")
+	}
+	var source StatsSource
+	if options.Containers != "" {
+		var err error
+		source, err = newStatsSource(options)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if options.Profile != "" {
+		if options.Concurrency > 0 {
+			usageError("flag -profile cannot be used with -concurrency")
+		}
+		if _, err := parseProfile(options.Profile, options.RPS, options.TestDuration); err != nil {
+			usageError("flag -profile: %s", err)
+		}
+	}
+
+	if options.Capacity && options.CapacityMax == 0 {
+		usageError("flag -capacity-max must be positive when -capacity is provided")
+	}
+
+	load := Load{
+		RPS:         options.RPS,
+		Profile:     options.Profile,
+		Concurrency: options.Concurrency,
+		ThinkTime:   options.ThinkTime,
+	}
+	if options.Targets != "" {
+		load.Targets = readTargets(options.Targets)
 	}
+	options.Model = load.Model()
 
 	log.Printf("Target is %q", options.TargetURL)
+	for _, t := range load.Targets {
+		log.Printf("Endpoint %q with weight %d", t, t.Weight)
+	}
+
+	// Stop early on SIGTERM, e.g. from "docker stop", saving the results
+	// collected so far.
+	stopOnSignal()
 
 	waitUntilReady(options.TargetURL, options.MaxWait)
+
+	var s *sampler
+	if source != nil && options.SampleInterval > 0 {
+		s = startSampling(source, strings.Split(options.Containers, ","), options.SampleInterval)
+	}
+
 	if options.WarmupDuration > 0 {
-		warmUp(options.TargetURL, options.RPS, options.WarmupDuration)
+		warmUp(options.TargetURL, load, options.WarmupDuration)
+	}
+	if stopped() {
+		panic(fmt.Errorf("%w during warmup", errStopped))
 	}
 
 	stats := make(map[string]Stats)
-	if options.CAdvisorURL != "" {
+	if source != nil {
 		for _, containerName := range strings.Split(options.Containers, ",") {
 			imageName := strings.Split(containerName, "-")[0]
 
 			stats[imageName] = Stats{
-				Before: containerStats(options.CAdvisorURL, containerName),
+				Before: containerStats(source, containerName),
 			}
 		}
 	}
 
-	r := test(options.TargetURL, options.RPS, options.TestDuration)
+	results := createResults(filepath.Join(options.Out, resultsFile))
+	endpoints := newEndpointMetrics(load.Targets)
+	r := test(options.TargetURL, load, options.TestDuration, func(r *vegeta.Result) {
+		results.Write(r)
+		endpoints.Add(r)
+	})
+	if err := results.Close(); err != nil {
+		panic(err)
+	}
 	metrics := r.Metrics
 
-	if options.CAdvisorURL != "" {
+	var samples map[string][]ContainerStats
+	if s != nil {
+		samples = s.Stop()
+	}
+
+	if source != nil {
 		for _, containerName := range strings.Split(options.Containers, ",") {
 			imageName := strings.Split(containerName, "-")[0]
 
-			after := containerStats(options.CAdvisorURL, containerName)
+			after := containerStats(source, containerName)
 			before := stats[imageName].Before
 
 			stats[imageName] = Stats{
-				Before: before,
-				After:  after,
-				Difference: ContainerStatsDifference{
-					Duration:            after.Timestamp.Sub(before.Timestamp),
-					MemoryMaxUsageBytes: int64(after.MemoryMaxUsageBytes - before.MemoryMaxUsageBytes),
-					CPUUsageUser:        int64(after.CPUUsageUser - before.CPUUsageUser),
-					CPUUsageSystem:      int64(after.CPUUsageSystem - before.CPUUsageSystem),
-					CPUUsageTotal:       int64(after.CPUUsageTotal - before.CPUUsageTotal),
-				},
+				Before:     before,
+				After:      after,
+				Difference: difference(before, after),
+				Samples:    samples[containerName],
 			}
 		}
 	}
 
 	result := TestResult{
 		FirstAppResponse: r.FirstResponse,
-		LoadGenResult:    r.Res,
+		Results:          resultsFile,
 		Metrics:          metrics,
+		Phases:           &r.Phases,
 		LoadGenCommand:   strings.Join(os.Args, " "),
 		Stats:            stats,
 		Options:          options,
+		Stopped:          stopped(),
+	}
+	if len(load.Targets) > 0 {
+		result.Endpoints = endpointResults(load.Targets, endpoints)
 	}
 	if options.FakerelayURL != "" {
 		result.RelayMetrics = relayMetrics(options.FakerelayURL)
 	}
+	if options.Capacity && !stopped() {
+		c := searchCapacity(load.targeter(options.TargetURL), options.RPS, options.CapacityMax, options.CapacityStep, SLO{
+			Success: options.SLOSuccess,
+			P99:     options.SLOP99,
+		})
+		// An interrupted search underestimates the capacity.
+		if stopped() {
+			log.Print("Capacity search stopped early, discarding result")
+		} else {
+			result.Capacity = &c
+		}
+	}
 
 	save(result, options.Out)
 
+	if result.Stopped {
+		log.Print("Saved partial result")
+		return
+	}
 	log.Print("Success")
 }
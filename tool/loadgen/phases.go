@@ -0,0 +1,200 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// PhaseMetrics are latency metrics of the phases of the requests of a test, as
+// seen by the HTTP client. Overhead of instrumentation typically shows in the
+// time to first byte, while connection setup is independent of the app.
+type PhaseMetrics struct {
+	// Connect is the time to dial new connections, including DNS lookup.
+	// Requests on reused connections are not counted.
+	Connect PhaseLatency `json:"connect"`
+	// TTFB is the time from writing the request to receiving the first
+	// byte of the response, that is, the processing time of the server.
+	TTFB PhaseLatency `json:"ttfb"`
+	// BodyRead is the time from the first byte of the response to the end
+	// of the response body.
+	BodyRead PhaseLatency `json:"body_read"`
+}
+
+// PhaseLatency is the latency of a phase over all requests that went through
+// it.
+type PhaseLatency struct {
+	Count uint64 `json:"count"`
+	vegeta.LatencyMetrics
+}
+
+func (l *PhaseLatency) add(d time.Duration) {
+	l.Count++
+	l.LatencyMetrics.Add(d)
+}
+
+func (l *PhaseLatency) close() {
+	if l.Count == 0 {
+		return
+	}
+	l.Mean = time.Duration(float64(l.Total) / float64(l.Count))
+	l.P50 = l.Quantile(0.50)
+	l.P90 = l.Quantile(0.90)
+	l.P95 = l.Quantile(0.95)
+	l.P99 = l.Quantile(0.99)
+}
+
+// phaseRecorder records the phases of requests made through its client.
+type phaseRecorder struct {
+	mu      sync.Mutex
+	metrics PhaseMetrics
+}
+
+// client returns an HTTP client that records the phases of its requests to
+// p, configured like the default client of a vegeta.Attacker with at most
+// maxIdle idle connections.
+func (p *phaseRecorder) client(maxIdle int) *http.Client {
+	dialer := &net.Dialer{
+		LocalAddr: &net.TCPAddr{IP: vegeta.DefaultLocalAddr.IP, Zone: vegeta.DefaultLocalAddr.Zone},
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Timeout: vegeta.DefaultTimeout,
+		Transport: &tracingTransport{
+			RoundTripper: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				DialContext:         dialer.DialContext,
+				TLSClientConfig:     vegeta.DefaultTLSConfig,
+				MaxIdleConnsPerHost: maxIdle,
+				MaxConnsPerHost:     vegeta.DefaultMaxConnections,
+			},
+			phases: p,
+		},
+	}
+}
+
+// Metrics returns the metrics of all requests recorded so far.
+func (p *phaseRecorder) Metrics() PhaseMetrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m := p.metrics
+	for _, l := range []*PhaseLatency{&m.Connect, &m.TTFB, &m.BodyRead} {
+		l.close()
+	}
+	return m
+}
+
+func (p *phaseRecorder) add(l *PhaseLatency, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l.add(d)
+}
+
+// tracingTransport records the phases of every request with httptrace.
+type tracingTransport struct {
+	http.RoundTripper
+	phases *phaseRecorder
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr := &requestTrace{phases: t.phases}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), tr.clientTrace()))
+	res, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = &tracedBody{ReadCloser: res.Body, trace: tr}
+	return res, nil
+}
+
+// requestTrace holds the times of the events of a single request. Callbacks
+// of a dial may run after the request has completed, so times are guarded by
+// a mutex.
+type requestTrace struct {
+	phases *phaseRecorder
+
+	mu                sync.Mutex
+	dialStart         time.Time
+	wroteRequest      time.Time
+	firstResponseByte time.Time
+	bodyReadRecorded  bool
+}
+
+func (tr *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { tr.startDial() },
+		ConnectStart: func(string, string) { tr.startDial() },
+		ConnectDone: func(_, _ string, err error) {
+			tr.mu.Lock()
+			start := tr.dialStart
+			tr.mu.Unlock()
+			if err == nil && !start.IsZero() {
+				tr.phases.add(&tr.phases.metrics.Connect, time.Since(start))
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			tr.mu.Lock()
+			defer tr.mu.Unlock()
+			if info.Err == nil {
+				tr.wroteRequest = time.Now()
+			}
+		},
+		GotFirstResponseByte: func() {
+			tr.mu.Lock()
+			tr.firstResponseByte = time.Now()
+			wrote := tr.wroteRequest
+			tr.mu.Unlock()
+			if !wrote.IsZero() {
+				tr.phases.add(&tr.phases.metrics.TTFB, tr.firstResponseByte.Sub(wrote))
+			}
+		},
+	}
+}
+
+// startDial records the start of dialing, which is the DNS lookup if the
+// address is a host name.
+func (tr *requestTrace) startDial() {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.dialStart.IsZero() {
+		tr.dialStart = time.Now()
+	}
+}
+
+// endBody records the time to read the body, once.
+func (tr *requestTrace) endBody() {
+	tr.mu.Lock()
+	if tr.bodyReadRecorded || tr.firstResponseByte.IsZero() {
+		tr.mu.Unlock()
+		return
+	}
+	tr.bodyReadRecorded = true
+	d := time.Since(tr.firstResponseByte)
+	tr.mu.Unlock()
+	tr.phases.add(&tr.phases.metrics.BodyRead, d)
+}
+
+// tracedBody ends the body read phase of a request at the end of its body, or
+// when it is closed before.
+type tracedBody struct {
+	io.ReadCloser
+	trace *requestTrace
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.trace.endBody()
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	b.trace.endBody()
+	return b.ReadCloser.Close()
+}
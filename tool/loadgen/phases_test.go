@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadFetchPhases(t *testing.T) {
+	const (
+		processing = 20 * time.Millisecond
+		streaming  = 10 * time.Millisecond
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(processing)
+		w.Write([]byte("Hello, "))
+		w.(http.Flusher).Flush()
+		time.Sleep(streaming)
+		w.Write([]byte("World!"))
+	}))
+	defer srv.Close()
+
+	for _, load := range []Load{
+		{RPS: 20},
+		{Concurrency: 2},
+	} {
+		t.Run(load.Model(), func(t *testing.T) {
+			r := load.fetch(srv.URL, 300*time.Millisecond, nil)
+			p := r.Phases
+			if p.TTFB.Count != r.Metrics.Requests || p.BodyRead.Count != r.Metrics.Requests {
+				t.Errorf("counted %d TTFB and %d body reads, want %d", p.TTFB.Count, p.BodyRead.Count, r.Metrics.Requests)
+			}
+			if p.Connect.Count == 0 || p.Connect.Count > p.TTFB.Count {
+				t.Errorf("counted %d connects, want between 1 and %d", p.Connect.Count, p.TTFB.Count)
+			}
+			if p.TTFB.Min < processing {
+				t.Errorf("TTFB.Min = %v, want at least %v", p.TTFB.Min, processing)
+			}
+			if p.BodyRead.Min < streaming {
+				t.Errorf("BodyRead.Min = %v, want at least %v", p.BodyRead.Min, streaming)
+			}
+			if p.TTFB.P50 == 0 || p.TTFB.P99 < p.TTFB.P50 {
+				t.Errorf("TTFB percentiles P50 = %v, P99 = %v, want 0 < P50 <= P99", p.TTFB.P50, p.TTFB.P99)
+			}
+		})
+	}
+}
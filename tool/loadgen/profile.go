@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// parseProfile returns a vegeta.Pacer for a load profile described by s.
+//
+// The format of s is "TYPE:KEY=VALUE,KEY=VALUE,...". The base rate of every
+// profile is rps. Supported profiles are:
+//
+//	ramp:to=N                      linear ramp from rps to N over the duration d
+//	steps:step=N,every=D           staircase adding N rps every D
+//	spike:peak=N,every=D,length=D  spikes to N rps lasting length every D
+//	sine:amp=N,period=D            sine wave around rps with amplitude N
+func parseProfile(s string, rps uint, d time.Duration) (vegeta.Pacer, error) {
+	typ, params, err := parseProfileParams(s)
+	if err != nil {
+		return nil, err
+	}
+	base := float64(rps)
+	switch typ {
+	case "ramp":
+		to, err := params.rate("to")
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("profile %q: ramp requires a test duration", s)
+		}
+		return vegeta.LinearPacer{
+			StartAt: vegeta.Rate{Freq: int(rps), Per: time.Second},
+			Slope:   (to - base) / d.Seconds(),
+		}, nil
+	case "steps":
+		step, err := params.rate("step")
+		if err != nil {
+			return nil, err
+		}
+		every, err := params.duration("every")
+		if err != nil {
+			return nil, err
+		}
+		return stepPacer{base: base, step: step, every: every}, nil
+	case "spike":
+		peak, err := params.rate("peak")
+		if err != nil {
+			return nil, err
+		}
+		every, err := params.duration("every")
+		if err != nil {
+			return nil, err
+		}
+		length, err := params.duration("length")
+		if err != nil {
+			return nil, err
+		}
+		if length > every {
+			return nil, fmt.Errorf("profile %q: spike length longer than interval", s)
+		}
+		return spikePacer{base: base, peak: peak, every: every, length: length}, nil
+	case "sine":
+		amp, err := params.rate("amp")
+		if err != nil {
+			return nil, err
+		}
+		period, err := params.duration("period")
+		if err != nil {
+			return nil, err
+		}
+		if amp > base {
+			return nil, fmt.Errorf("profile %q: amplitude larger than base rate", s)
+		}
+		return vegeta.SinePacer{
+			Period:  period,
+			Mean:    vegeta.Rate{Freq: int(rps), Per: time.Second},
+			Amp:     vegeta.Rate{Freq: int(amp), Per: time.Second},
+			StartAt: vegeta.MeanUp,
+		}, nil
+	default:
+		return nil, fmt.Errorf("profile %q: unknown type %q", s, typ)
+	}
+}
+
+type profileParams map[string]string
+
+func parseProfileParams(s string) (string, profileParams, error) {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return "", nil, fmt.Errorf("profile %q: missing parameters", s)
+	}
+	params := make(profileParams)
+	for _, kv := range strings.Split(s[i+1:], ",") {
+		j := strings.Index(kv, "=")
+		if j < 0 {
+			return "", nil, fmt.Errorf("profile %q: invalid parameter %q", s, kv)
+		}
+		params[kv[:j]] = kv[j+1:]
+	}
+	return s[:i], params, nil
+}
+
+func (p profileParams) rate(key string) (float64, error) {
+	v, err := strconv.ParseUint(p[key], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("profile parameter %q: invalid rate: %q", key, p[key])
+	}
+	return float64(v), nil
+}
+
+func (p profileParams) duration(key string) (time.Duration, error) {
+	d, err := time.ParseDuration(p[key])
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("profile parameter %q: invalid duration: %q", key, p[key])
+	}
+	return d, nil
+}
+
+// stepPacer paces an attack in a staircase, starting at base requests per
+// second and adding step requests per second every interval.
+type stepPacer struct {
+	base, step float64
+	every      time.Duration
+}
+
+func (p stepPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	return pace(p, elapsed, hits)
+}
+
+func (p stepPacer) Rate(elapsed time.Duration) float64 {
+	return p.base + p.step*math.Floor(float64(elapsed)/float64(p.every))
+}
+
+func (p stepPacer) hits(t time.Duration) float64 {
+	if t <= 0 {
+		return 0
+	}
+	n := math.Floor(float64(t) / float64(p.every))
+	every := p.every.Seconds()
+	// n complete steps, plus the partial current step
+	complete := n*p.base*every + p.step*every*n*(n-1)/2
+	return complete + p.Rate(t)*(t.Seconds()-n*every)
+}
+
+// spikePacer paces an attack at base requests per second, except for spikes
+// of peak requests per second lasting length every interval.
+type spikePacer struct {
+	base, peak    float64
+	every, length time.Duration
+}
+
+func (p spikePacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	return pace(p, elapsed, hits)
+}
+
+func (p spikePacer) Rate(elapsed time.Duration) float64 {
+	if elapsed%p.every < p.length {
+		return p.peak
+	}
+	return p.base
+}
+
+func (p spikePacer) hits(t time.Duration) float64 {
+	if t <= 0 {
+		return 0
+	}
+	n := t / p.every
+	inSpike := t - n*p.every
+	if inSpike > p.length {
+		inSpike = p.length
+	}
+	spiking := time.Duration(n)*p.length + inSpike
+	return p.base*t.Seconds() + (p.peak-p.base)*spiking.Seconds()
+}
+
+// integralPacer is a pacer for which the number of hits expected at any time
+// is known.
+type integralPacer interface {
+	Rate(elapsed time.Duration) float64
+	hits(t time.Duration) float64
+}
+
+// pace implements vegeta.Pacer for an integralPacer, following the same logic
+// as the pacers in the vegeta package.
+func pace(p integralPacer, elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	expectedHits := p.hits(elapsed)
+	if hits == 0 || hits < uint64(expectedHits) {
+		// Running behind, send next hit immediately.
+		return 0, false
+	}
+	rate := p.Rate(elapsed)
+	if rate <= 0 {
+		return 0, true
+	}
+	interval := 1e9 / rate
+	delta := float64(hits+1) - expectedHits
+	return time.Duration(interval * delta), false
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestParseProfile(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    vegeta.Pacer
+		wantErr bool
+	}{
+		{
+			in:   "ramp:to=30",
+			want: vegeta.LinearPacer{StartAt: vegeta.Rate{Freq: 10, Per: time.Second}, Slope: 2},
+		},
+		{
+			in:   "steps:step=5,every=1s",
+			want: stepPacer{base: 10, step: 5, every: time.Second},
+		},
+		{
+			in:   "spike:peak=50,every=10s,length=2s",
+			want: spikePacer{base: 10, peak: 50, every: 10 * time.Second, length: 2 * time.Second},
+		},
+		{
+			in: "sine:amp=5,period=30s",
+			want: vegeta.SinePacer{
+				Period:  30 * time.Second,
+				Mean:    vegeta.Rate{Freq: 10, Per: time.Second},
+				Amp:     vegeta.Rate{Freq: 5, Per: time.Second},
+				StartAt: vegeta.MeanUp,
+			},
+		},
+		{in: "ramp", wantErr: true},
+		{in: "ramp:to", wantErr: true},
+		{in: "ramp:to=-1", wantErr: true},
+		{in: "steps:step=5,every=0s", wantErr: true},
+		{in: "spike:peak=50,every=1s,length=2s", wantErr: true},
+		{in: "sine:amp=50,period=30s", wantErr: true},
+		{in: "square:freq=1", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseProfile(tt.in, 10, 10*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseProfile() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseProfile("ramp:to=30", 10, 0); err == nil {
+		t.Errorf("ramp without test duration succeeded, want error")
+	}
+}
+
+func TestProfilePacers(t *testing.T) {
+	steps := stepPacer{base: 10, step: 5, every: time.Second}
+	spike := spikePacer{base: 10, peak: 50, every: 10 * time.Second, length: 2 * time.Second}
+	tests := []struct {
+		name     string
+		pacer    integralPacer
+		elapsed  time.Duration
+		wantRate float64
+		wantHits float64
+	}{
+		{"steps/start", steps, 0, 10, 0},
+		{"steps/first step", steps, 500 * time.Millisecond, 10, 5},
+		{"steps/second step", steps, time.Second, 15, 10},
+		{"steps/within second step", steps, 1500 * time.Millisecond, 15, 17.5},
+		{"steps/third step", steps, 2 * time.Second, 20, 25},
+		{"spike/start", spike, 0, 50, 0},
+		{"spike/in spike", spike, time.Second, 50, 50},
+		{"spike/end of spike", spike, 2 * time.Second, 10, 100},
+		{"spike/between spikes", spike, 5 * time.Second, 10, 130},
+		{"spike/second spike", spike, 11 * time.Second, 50, 230},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pacer.Rate(tt.elapsed); got != tt.wantRate {
+				t.Errorf("Rate(%v) = %v, want %v", tt.elapsed, got, tt.wantRate)
+			}
+			if got := tt.pacer.hits(tt.elapsed); math.Abs(got-tt.wantHits) > 1e-9 {
+				t.Errorf("hits(%v) = %v, want %v", tt.elapsed, got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestProfilePace(t *testing.T) {
+	steps := stepPacer{base: 10, step: 5, every: time.Second}
+	spike := spikePacer{base: 10, peak: 50, every: 10 * time.Second, length: 2 * time.Second}
+	p, err := parseProfile("sine:amp=5,period=30s", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sine := p.(vegeta.SinePacer)
+	// The sine wave starts at the base rate, going up.
+	for _, tt := range []struct {
+		elapsed time.Duration
+		want    float64
+	}{{0, 10}, {7500 * time.Millisecond, 15}, {15 * time.Second, 10}, {22500 * time.Millisecond, 5}} {
+		if got := sine.Rate(tt.elapsed); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("sine Rate(%v) = %v, want %v", tt.elapsed, got, tt.want)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pacer    vegeta.Pacer
+		elapsed  time.Duration
+		hits     uint64
+		wantWait time.Duration
+	}{
+		{"steps/first hit", steps, 0, 0, 0},
+		{"steps/behind", steps, time.Second, 5, 0},
+		{"steps/on time", steps, time.Second, 10, time.Second / 15},
+		{"steps/ahead", steps, time.Second, 11, 2 * time.Second / 15},
+		{"spike/on time in spike", spike, time.Second, 50, time.Second / 50},
+		{"spike/on time between spikes", spike, 5 * time.Second, 130, time.Second / 10},
+		{"sine/behind", sine, 7500 * time.Millisecond, 50, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, stop := tt.pacer.Pace(tt.elapsed, tt.hits)
+			if stop {
+				t.Fatalf("Pace(%v, %d) stopped the attack", tt.elapsed, tt.hits)
+			}
+			if d := wait - tt.wantWait; d < -time.Microsecond || d > time.Microsecond {
+				t.Errorf("Pace(%v, %d) = %v, want %v", tt.elapsed, tt.hits, wait, tt.wantWait)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// resultsFile is the name of the file with the result of every request of the
+// test, written as gzip-compressed JSON lines in the format of "vegeta encode
+// -to json".
+const resultsFile = "results.jsonl.gz"
+
+// resultWriter streams results to a file as they arrive, such that loadgen
+// does not hold all results of the test in memory. Response bodies and headers
+// are not written, the first response is kept in TestResult.
+type resultWriter struct {
+	f   *os.File
+	buf *bufio.Writer
+	gz  *gzip.Writer
+	enc vegeta.Encoder
+	err error // first error, reported by Close
+}
+
+// createResults creates the results file at path, creating its directory if
+// needed.
+func createResults(path string) *resultWriter {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		panic(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	w := &resultWriter{f: f, buf: bufio.NewWriter(f)}
+	w.gz = gzip.NewWriter(w.buf)
+	w.enc = vegeta.NewJSONEncoder(w.gz)
+	return w
+}
+
+// Write writes a result. After the first error, results are dropped.
+func (w *resultWriter) Write(r *vegeta.Result) {
+	if w.err != nil {
+		return
+	}
+	res := *r
+	res.Body, res.Headers = nil, nil
+	w.err = w.enc.Encode(&res)
+}
+
+// Close flushes and closes the file and returns the first error, if any.
+func (w *resultWriter) Close() error {
+	for _, close := range []func() error{w.gz.Close, w.buf.Flush, w.f.Close} {
+		if err := close(); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+	return w.err
+}
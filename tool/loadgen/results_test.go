@@ -0,0 +1,63 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestResultWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", resultsFile)
+	w := createResults(path)
+	start := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		w.Write(&vegeta.Result{
+			Seq:       uint64(i),
+			Code:      200,
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Latency:   time.Millisecond,
+			BytesIn:   5,
+			Body:      []byte("hello"),
+			Method:    "GET",
+			URL:       "http://app:8080/",
+		})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := vegeta.NewJSONDecoder(gz)
+	var n int
+	for {
+		var r vegeta.Result
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if r.Seq != uint64(n) || !r.Timestamp.Equal(start.Add(time.Duration(n)*time.Second)) || r.BytesIn != 5 {
+			t.Errorf("result %d: got %+v", n, r)
+		}
+		if len(r.Body) > 0 {
+			t.Errorf("result %d: got body %q, want none", n, r.Body)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("got %d results, want 3", n)
+	}
+}
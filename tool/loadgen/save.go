@@ -14,13 +14,28 @@ import (
 
 type Options struct {
 	TargetURL      string        `json:"target_url"`
+	Targets        string        `json:"targets,omitempty"`
+	StatsSource    string        `json:"stats_source,omitempty"`
 	CAdvisorURL    string        `json:"cadvisor_url"`
+	DockerSocket   string        `json:"docker_socket,omitempty"`
+	CgroupRoot     string        `json:"cgroup_root,omitempty"`
+	ProcRoot       string        `json:"proc_root,omitempty"`
 	FakerelayURL   string        `json:"fakerelay_url"`
 	Containers     string        `json:"containers"`
 	MaxWait        time.Duration `json:"max_wait"`
 	WarmupDuration time.Duration `json:"warmup_duration"`
 	TestDuration   time.Duration `json:"test_duration"`
+	Model          string        `json:"model"` // "open" or "closed"
 	RPS            uint          `json:"rps"`
+	Profile        string        `json:"profile,omitempty"`
+	Concurrency    uint          `json:"concurrency"`
+	ThinkTime      time.Duration `json:"think_time"`
+	Capacity       bool          `json:"capacity"`
+	SLOSuccess     float64       `json:"slo_success"`
+	SLOP99         time.Duration `json:"slo_p99"`
+	CapacityStep   time.Duration `json:"capacity_step"`
+	CapacityMax    uint          `json:"capacity_max"`
+	SampleInterval time.Duration `json:"sample_interval"`
 	Out            string        `json:"out"`
 }
 
@@ -28,11 +43,15 @@ type Options struct {
 type TestResult struct {
 	FirstAppResponse string
 	*vegeta.Metrics
-	LoadGenResult  []*vegeta.Result       `json:"loadgen_result"`
+	Results        string                 `json:"results,omitempty"` // name of the file with the result of every request, see resultsFile
 	Stats          map[string]Stats       `json:"container_stats"`
 	RelayMetrics   map[string]interface{} `json:"relay_metrics,omitempty"`
 	LoadGenCommand string                 `json:"loadgen_command"`
 	Options        Options                `json:"options"`
+	Capacity       *CapacityResult        `json:"capacity,omitempty"`
+	Endpoints      []EndpointResult       `json:"endpoints,omitempty"`
+	Phases         *PhaseMetrics          `json:"phases,omitempty"`
+	Stopped        bool                   `json:"stopped,omitempty"` // stopped early, the test is incomplete
 }
 
 // save writes reports computed from metrics to the output path.
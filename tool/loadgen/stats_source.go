@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cadvisor "github.com/google/cadvisor/client/v2"
+	cadvisor_info "github.com/google/cadvisor/info/v2"
+)
+
+// StatsSource reports the resource usage of containers. CPU usage is
+// cumulative, in nanoseconds. Implementations are safe for concurrent use.
+type StatsSource interface {
+	// Stats returns the current resource usage of the container with the
+	// given name.
+	Stats(containerName string) (ContainerStats, error)
+	// String describes the source for logging.
+	String() string
+}
+
+// StatsSources lists the names of the supported stats sources.
+var StatsSources = []string{"cadvisor", "docker", "cgroup"}
+
+// newStatsSource returns the stats source selected by the options.
+func newStatsSource(options Options) (StatsSource, error) {
+	switch options.StatsSource {
+	case "cadvisor":
+		if options.CAdvisorURL == "" {
+			return nil, errors.New("flag -cadvisor is required with -stats cadvisor")
+		}
+		return newCAdvisorSource(options.CAdvisorURL)
+	case "docker":
+		return newDockerSource(options.DockerSocket), nil
+	case "cgroup":
+		// Container names are resolved to IDs with the Docker Engine
+		// API once, stats are read from the cgroup files.
+		return newCgroupSource(options.CgroupRoot, options.ProcRoot, newDockerSource(options.DockerSocket).containerID)
+	}
+	return nil, fmt.Errorf("unknown stats source %q, want one of: %s", options.StatsSource, strings.Join(StatsSources, ", "))
+}
+
+// cadvisorSource reads stats from cAdvisor, which runs in its own container
+// with access to the Docker socket and the host file system.
+type cadvisorSource struct {
+	url    string
+	client *cadvisor.Client
+}
+
+func newCAdvisorSource(cAdvisorURL string) (*cadvisorSource, error) {
+	client, err := cadvisor.NewClient(cAdvisorURL)
+	if err != nil {
+		return nil, err
+	}
+	return &cadvisorSource{url: cAdvisorURL, client: client}, nil
+}
+
+func (s *cadvisorSource) String() string { return fmt.Sprintf("cAdvisor %q", s.url) }
+
+func (s *cadvisorSource) Stats(containerName string) (ContainerStats, error) {
+	opts := &cadvisor_info.RequestOptions{
+		IdType: cadvisor_info.TypeDocker,
+		Count:  1,
+	}
+	m, err := s.client.Stats(containerName, opts)
+	if err != nil {
+		return ContainerStats{}, err
+	}
+	for _, v := range m {
+		if len(v.Stats) == 0 {
+			break
+		}
+		st := v.Stats[0]
+		cs := ContainerStats{Timestamp: st.Timestamp}
+		if st.Memory != nil {
+			cs.MemoryUsageBytes = st.Memory.Usage
+			cs.MemoryMaxUsageBytes = st.Memory.MaxUsage
+			cs.MemoryWorkingSetBytes = st.Memory.WorkingSet
+		}
+		if st.Cpu != nil {
+			cs.CPUUsageUser = st.Cpu.Usage.User
+			cs.CPUUsageSystem = st.Cpu.Usage.System
+			cs.CPUUsageTotal = st.Cpu.Usage.Total
+		}
+		if st.Network != nil {
+			for _, i := range st.Network.Interfaces {
+				if i.Name == "lo" {
+					continue
+				}
+				cs.NetworkRxBytes += i.RxBytes
+				cs.NetworkRxPackets += i.RxPackets
+				cs.NetworkTxBytes += i.TxBytes
+				cs.NetworkTxPackets += i.TxPackets
+			}
+		}
+		if st.DiskIo != nil {
+			for _, d := range st.DiskIo.IoServiceBytes {
+				cs.BlockReadBytes += d.Stats["Read"]
+				cs.BlockWriteBytes += d.Stats["Write"]
+			}
+		}
+		return cs, nil
+	}
+	return ContainerStats{}, fmt.Errorf("missing cAdvisor stats for container %q", containerName)
+}
+
+// dockerSource reads stats from the Docker Engine API over its unix socket,
+// like "docker stats".
+type dockerSource struct {
+	socket string
+	client *http.Client
+	peaks  peakMemory
+}
+
+func newDockerSource(socket string) *dockerSource {
+	return &dockerSource{
+		socket: socket,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (s *dockerSource) String() string { return fmt.Sprintf("Docker Engine API at %q", s.socket) }
+
+// dockerStats is the part of the response of the container stats endpoint
+// used by loadgen. CPU usage is in nanoseconds.
+type dockerStats struct {
+	Read     time.Time `json:"read"`
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage        uint64 `json:"total_usage"`
+			UsageInKernelmode uint64 `json:"usage_in_kernelmode"`
+			UsageInUsermode   uint64 `json:"usage_in_usermode"`
+		} `json:"cpu_usage"`
+	} `json:"cpu_stats"`
+	MemoryStats struct {
+		Usage    uint64            `json:"usage"`
+		MaxUsage uint64            `json:"max_usage"` // cgroup v1 only
+		Stats    map[string]uint64 `json:"stats"`     // from memory.stat
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes   uint64 `json:"rx_bytes"`
+		RxPackets uint64 `json:"rx_packets"`
+		TxBytes   uint64 `json:"tx_bytes"`
+		TxPackets uint64 `json:"tx_packets"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IoServiceBytesRecursive []struct {
+			Op    string `json:"op"` // like "Read" (cgroup v1) or "read" (cgroup v2)
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+}
+
+func (s *dockerSource) Stats(containerName string) (ContainerStats, error) {
+	// Without one-shot, the engine waits for a second sample to compute
+	// the CPU usage since the previous one, which loadgen does not need.
+	var v dockerStats
+	if err := s.get("/containers/"+url.PathEscape(containerName)+"/stats?stream=false&one-shot=true", &v); err != nil {
+		return ContainerStats{}, err
+	}
+	if v.Read.IsZero() {
+		v.Read = time.Now()
+	}
+	cs := ContainerStats{
+		Timestamp:           v.Read,
+		MemoryUsageBytes:    v.MemoryStats.Usage,
+		MemoryMaxUsageBytes: s.peaks.update(containerName, v.MemoryStats.Usage, v.MemoryStats.MaxUsage),
+		CPUUsageUser:        v.CPUStats.CPUUsage.UsageInUsermode,
+		CPUUsageSystem:      v.CPUStats.CPUUsage.UsageInKernelmode,
+		CPUUsageTotal:       v.CPUStats.CPUUsage.TotalUsage,
+	}
+	// Like "docker stats", with the key of cgroup v1 or v2.
+	inactive, ok := v.MemoryStats.Stats["total_inactive_file"]
+	if !ok {
+		inactive = v.MemoryStats.Stats["inactive_file"]
+	}
+	cs.MemoryWorkingSetBytes = workingSet(v.MemoryStats.Usage, inactive)
+	for name, n := range v.Networks {
+		if name == "lo" {
+			continue
+		}
+		cs.NetworkRxBytes += n.RxBytes
+		cs.NetworkRxPackets += n.RxPackets
+		cs.NetworkTxBytes += n.TxBytes
+		cs.NetworkTxPackets += n.TxPackets
+	}
+	for _, e := range v.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(e.Op) {
+		case "read":
+			cs.BlockReadBytes += e.Value
+		case "write":
+			cs.BlockWriteBytes += e.Value
+		}
+	}
+	return cs, nil
+}
+
+// containerID returns the full ID of the container with the given name.
+func (s *dockerSource) containerID(containerName string) (string, error) {
+	var v struct {
+		ID string `json:"Id"`
+	}
+	if err := s.get("/containers/"+url.PathEscape(containerName)+"/json", &v); err != nil {
+		return "", err
+	}
+	return v.ID, nil
+}
+
+// get requests path from the Docker Engine API and decodes the JSON response
+// into v.
+func (s *dockerSource) get(path string, v interface{}) error {
+	// The host is ignored, requests go to the socket.
+	resp, err := s.client.Get("http://docker" + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Message string `json:"message"`
+		}
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(b, &e) != nil || e.Message == "" {
+			e.Message = strings.TrimSpace(string(b))
+		}
+		return fmt.Errorf("docker: GET %s: %s: %s", path, resp.Status, e.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// cgroupSource reads stats directly from the cgroup v2 files of containers,
+// which costs less CPU than asking cAdvisor or the Docker Engine. The root of
+// the host's cgroup hierarchy must be mounted at root.
+//
+// Network counters are not part of cgroups. They are read from the network
+// namespace of the first process of a container, which requires the host's
+// proc file system mounted at proc. If proc is empty, they are zero.
+type cgroupSource struct {
+	root    string
+	proc    string
+	resolve func(containerName string) (string, error) // container name to ID
+
+	mu    sync.Mutex
+	dirs  map[string]string // cgroup directory by container name
+	peaks peakMemory
+}
+
+func newCgroupSource(root, proc string, resolve func(containerName string) (string, error)) (*cgroupSource, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%s is not a cgroup v2 hierarchy: %w", root, err)
+	}
+	return &cgroupSource{
+		root:    root,
+		proc:    proc,
+		resolve: resolve,
+		dirs:    make(map[string]string),
+	}, nil
+}
+
+func (s *cgroupSource) String() string { return fmt.Sprintf("cgroup v2 files in %q", s.root) }
+
+// cgroupDirs are the locations of the cgroup of a container with the given
+// ID, relative to the root of the hierarchy, with the systemd and cgroupfs
+// cgroup drivers of Docker and Podman.
+var cgroupDirs = []string{
+	"system.slice/docker-%s.scope",
+	"docker/%s",
+	"machine.slice/libpod-%s.scope",
+	"libpod_parent/libpod-%s",
+}
+
+// dir returns the cgroup directory of a container.
+func (s *cgroupSource) dir(containerName string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dir, ok := s.dirs[containerName]; ok {
+		return dir, nil
+	}
+	id, err := s.resolve(containerName)
+	if err != nil {
+		return "", err
+	}
+	for _, format := range cgroupDirs {
+		dir := filepath.Join(s.root, fmt.Sprintf(format, id))
+		if _, err := os.Stat(dir); err == nil {
+			s.dirs[containerName] = dir
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup for container %q (%s) in %s", containerName, id, s.root)
+}
+
+func (s *cgroupSource) Stats(containerName string) (ContainerStats, error) {
+	dir, err := s.dir(containerName)
+	if err != nil {
+		return ContainerStats{}, err
+	}
+	cs := ContainerStats{Timestamp: time.Now()}
+	cpu, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return ContainerStats{}, err
+	}
+	// cpu.stat is in microseconds.
+	cs.CPUUsageTotal = cpu["usage_usec"] * 1000
+	cs.CPUUsageUser = cpu["user_usec"] * 1000
+	cs.CPUUsageSystem = cpu["system_usec"] * 1000
+
+	cs.MemoryUsageBytes, err = readUint(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return ContainerStats{}, err
+	}
+	// memory.peak requires Linux 5.19.
+	peak, err := readUint(filepath.Join(dir, "memory.peak"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ContainerStats{}, err
+	}
+	cs.MemoryMaxUsageBytes = s.peaks.update(containerName, cs.MemoryUsageBytes, peak)
+	memory, err := readKeyValues(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return ContainerStats{}, err
+	}
+	cs.MemoryWorkingSetBytes = workingSet(cs.MemoryUsageBytes, memory["inactive_file"])
+
+	// io.stat is missing if the io controller is not enabled.
+	cs.BlockReadBytes, cs.BlockWriteBytes, err = readIOStat(filepath.Join(dir, "io.stat"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ContainerStats{}, err
+	}
+
+	if s.proc != "" {
+		if err := s.readNetDev(dir, &cs); err != nil {
+			return ContainerStats{}, err
+		}
+	}
+	return cs, nil
+}
+
+// readNetDev reads the network counters of the container with the cgroup
+// directory dir into cs.
+func (s *cgroupSource) readNetDev(dir string, cs *ContainerStats) error {
+	b, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return err
+	}
+	pids := strings.Fields(string(b))
+	if len(pids) == 0 {
+		return fmt.Errorf("%s: no processes", dir)
+	}
+	b, err = os.ReadFile(filepath.Join(s.proc, pids[0], "net", "dev"))
+	if err != nil {
+		return err
+	}
+	// After two header lines, every line is an interface like
+	// "eth0: RX bytes packets errs drop fifo frame compressed multicast
+	// TX bytes packets ...".
+	lines := strings.Split(string(b), "\n")
+	if len(lines) < 2 {
+		return fmt.Errorf("net/dev of process %s: missing header", pids[0])
+	}
+	for _, line := range lines[2:] {
+		i := strings.IndexByte(line, ':')
+		if i < 0 || strings.TrimSpace(line[:i]) == "lo" {
+			continue
+		}
+		fields := strings.Fields(line[i+1:])
+		if len(fields) < 10 {
+			continue
+		}
+		var v [4]uint64
+		for i, f := range []int{0, 1, 8, 9} {
+			if v[i], err = strconv.ParseUint(fields[f], 10, 64); err != nil {
+				return fmt.Errorf("net/dev of process %s: %w", pids[0], err)
+			}
+		}
+		cs.NetworkRxBytes += v[0]
+		cs.NetworkRxPackets += v[1]
+		cs.NetworkTxBytes += v[2]
+		cs.NetworkTxPackets += v[3]
+	}
+	return nil
+}
+
+// readIOStat returns the bytes read and written on all devices from a cgroup
+// io.stat file of lines like "8:0 rbytes=1 wbytes=2 rios=3 wios=4".
+func readIOStat(path string) (read, write uint64, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		for _, field := range strings.Fields(line) {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 || kv[0] != "rbytes" && kv[0] != "wbytes" {
+				continue
+			}
+			key, value := kv[0], kv[1]
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("%s: %w", path, err)
+			}
+			if key == "rbytes" {
+				read += n
+			} else {
+				write += n
+			}
+		}
+	}
+	return read, write, nil
+}
+
+// workingSet returns the memory usage without the inactive file cache, like
+// cAdvisor and "docker stats".
+func workingSet(usage, inactiveFile uint64) uint64 {
+	if inactiveFile > usage {
+		return 0
+	}
+	return usage - inactiveFile
+}
+
+// readKeyValues reads a cgroup file of lines like "usage_usec 1234".
+func readKeyValues(path string) (map[string]uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]uint64)
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		m[fields[0]] = v
+	}
+	return m, sc.Err()
+}
+
+// readUint reads a cgroup file with a single number.
+func readUint(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return v, nil
+}
+
+// peakMemory tracks the highest memory usage of containers for sources that
+// do not always report it, like cgroup v2 before Linux 5.19. The peak is then
+// the highest usage observed by loadgen, which may miss short spikes.
+type peakMemory struct {
+	mu    sync.Mutex
+	peaks map[string]uint64
+}
+
+// update records the usage of a container and returns its peak usage, taking
+// the peak reported by the source, if any.
+func (p *peakMemory) update(containerName string, usage, reported uint64) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peaks == nil {
+		p.peaks = make(map[string]uint64)
+	}
+	peak := p.peaks[containerName]
+	if usage > peak {
+		peak = usage
+	}
+	if reported > peak {
+		peak = reported
+	}
+	p.peaks[containerName] = peak
+	return peak
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cadvisor_v1 "github.com/google/cadvisor/info/v1"
+	cadvisor_info "github.com/google/cadvisor/info/v2"
+)
+
+var statsTime = time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+
+func TestCAdvisorSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2.1/stats/app-baseline-tbnfsga" {
+			http.NotFound(w, r)
+			return
+		}
+		stats := &cadvisor_info.ContainerStats{
+			Timestamp: statsTime,
+			Cpu: &cadvisor_v1.CpuStats{
+				Usage: cadvisor_v1.CpuUsage{Total: 3000, User: 2000, System: 1000},
+			},
+			Memory: &cadvisor_v1.MemoryStats{Usage: 100, MaxUsage: 200, WorkingSet: 80},
+			Network: &cadvisor_info.NetworkStats{
+				Interfaces: []cadvisor_v1.InterfaceStats{
+					{Name: "eth0", RxBytes: 10, RxPackets: 1, TxBytes: 20, TxPackets: 2},
+					{Name: "lo", RxBytes: 1000, RxPackets: 100, TxBytes: 1000, TxPackets: 100},
+				},
+			},
+			DiskIo: &cadvisor_v1.DiskIoStats{
+				IoServiceBytes: []cadvisor_v1.PerDiskStats{
+					{Device: "/dev/sda", Stats: map[string]uint64{"Read": 30, "Write": 40}},
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(map[string]cadvisor_info.ContainerInfo{
+			"/docker/0123": {Stats: []*cadvisor_info.ContainerStats{stats}},
+		})
+	}))
+	defer srv.Close()
+
+	source, err := newStatsSource(Options{StatsSource: "cadvisor", CAdvisorURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := source.Stats("app-baseline-tbnfsga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ContainerStats{
+		Timestamp:             statsTime,
+		MemoryUsageBytes:      100,
+		MemoryMaxUsageBytes:   200,
+		MemoryWorkingSetBytes: 80,
+		CPUUsageUser:          2000,
+		CPUUsageSystem:        1000,
+		CPUUsageTotal:         3000,
+		NetworkRxBytes:        10,
+		NetworkRxPackets:      1,
+		NetworkTxBytes:        20,
+		NetworkTxPackets:      2,
+		BlockReadBytes:        30,
+		BlockWriteBytes:       40,
+	}
+	if !got.Timestamp.Equal(want.Timestamp) {
+		t.Errorf("Timestamp = %v, want %v", got.Timestamp, want.Timestamp)
+	}
+	got.Timestamp = want.Timestamp
+	if got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+// fakeDocker serves the container stats and inspect endpoints of the Docker
+// Engine API on a unix socket and returns the path of the socket.
+func fakeDocker(t *testing.T, memory ...uint64) string {
+	t.Helper()
+	// The path of a unix socket is limited to about 100 bytes, which
+	// t.TempDir may exceed.
+	dir, err := os.MkdirTemp("", "loadgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests int
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/app-baseline-tbnfsga/stats":
+			if r.URL.Query().Get("stream") != "false" {
+				t.Errorf("stats requested with stream=%q, want false", r.URL.Query().Get("stream"))
+			}
+			// as reported on a cgroup v2 host
+			fmt.Fprintf(w, `{
+				"read": %q,
+				"cpu_stats": {"cpu_usage": {"total_usage": 3000, "usage_in_usermode": 2000, "usage_in_kernelmode": 1000}},
+				"memory_stats": {"usage": %d, "stats": {"inactive_file": 20}},
+				"networks": {"eth0": {"rx_bytes": 10, "rx_packets": 1, "tx_bytes": 20, "tx_packets": 2}},
+				"blkio_stats": {"io_service_bytes_recursive": [{"op": "read", "value": 30}, {"op": "write", "value": 40}]}
+			}`, statsTime.Add(time.Duration(requests)*time.Second).Format(time.RFC3339Nano), memory[requests%len(memory)])
+			requests++
+		case "/containers/app-baseline-tbnfsga/json":
+			w.Write([]byte(`{"Id": "0123abcd", "Name": "/app-baseline-tbnfsga"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "No such container"}`))
+		}
+	})}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return socket
+}
+
+func TestDockerSource(t *testing.T) {
+	socket := fakeDocker(t, 200, 100)
+	source, err := newStatsSource(Options{StatsSource: "docker", DockerSocket: socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cgroup v2 hosts do not report the peak memory usage, so the highest
+	// usage seen is reported instead.
+	for i, want := range []ContainerStats{
+		{MemoryUsageBytes: 200, MemoryMaxUsageBytes: 200, MemoryWorkingSetBytes: 180},
+		{MemoryUsageBytes: 100, MemoryMaxUsageBytes: 200, MemoryWorkingSetBytes: 80},
+	} {
+		got, err := source.Stats("app-baseline-tbnfsga")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Timestamp = statsTime.Add(time.Duration(i) * time.Second)
+		want.CPUUsageUser, want.CPUUsageSystem, want.CPUUsageTotal = 2000, 1000, 3000
+		want.NetworkRxBytes, want.NetworkRxPackets, want.NetworkTxBytes, want.NetworkTxPackets = 10, 1, 20, 2
+		want.BlockReadBytes, want.BlockWriteBytes = 30, 40
+		if !got.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("#%d: Timestamp = %v, want %v", i, got.Timestamp, want.Timestamp)
+		}
+		got.Timestamp = want.Timestamp
+		if got != want {
+			t.Errorf("#%d: Stats() = %+v, want %+v", i, got, want)
+		}
+	}
+
+	_, err = source.Stats("missing")
+	if err == nil || !strings.Contains(err.Error(), "No such container") {
+		t.Errorf("Stats(missing) error = %v, want No such container", err)
+	}
+}
+
+const netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000     100    0    0    0     0          0         0     1000     100    0    0    0     0       0          0
+  eth0:      10       1    0    0    0     0          0         0       20       2    0    0    0     0       0          0
+`
+
+func TestCgroupSource(t *testing.T) {
+	root := t.TempDir()
+	if _, err := newCgroupSource(root, "", nil); err == nil {
+		t.Errorf("newCgroupSource without cgroup.controllers succeeded, want error")
+	}
+
+	dir := filepath.Join(root, "system.slice", "docker-0123abcd.scope")
+	proc := t.TempDir()
+	for _, d := range []string{dir, filepath.Join(proc, "4242", "net")} {
+		if err := os.MkdirAll(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(root, "cgroup.controllers"): "cpu memory\n",
+		filepath.Join(dir, "cpu.stat"):            "usage_usec 3\nuser_usec 2\nsystem_usec 1\nnr_periods 0\n",
+		filepath.Join(dir, "memory.current"):      "100\n",
+		filepath.Join(dir, "memory.stat"):         "anon 60\nfile 40\ninactive_file 30\n",
+		filepath.Join(dir, "io.stat"):             "8:0 rbytes=10 wbytes=20 rios=1 wios=2\n8:16 rbytes=20 wbytes=20 rios=1 wios=1\n",
+		filepath.Join(dir, "cgroup.procs"):        "4242\n4243\n",
+		filepath.Join(proc, "4242", "net", "dev"): netDev,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	socket := fakeDocker(t, 0)
+	source, err := newStatsSource(Options{StatsSource: "cgroup", CgroupRoot: root, ProcRoot: proc, DockerSocket: socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := source.Stats("app-baseline-tbnfsga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ContainerStats{
+		Timestamp:             got.Timestamp,
+		MemoryUsageBytes:      100,
+		MemoryMaxUsageBytes:   100,
+		MemoryWorkingSetBytes: 70,
+		CPUUsageUser:          2000,
+		CPUUsageSystem:        1000,
+		CPUUsageTotal:         3000,
+		NetworkRxBytes:        10,
+		NetworkRxPackets:      1,
+		NetworkTxBytes:        20,
+		NetworkTxPackets:      2,
+		BlockReadBytes:        30,
+		BlockWriteBytes:       40,
+	}
+	if got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// memory.peak, available since Linux 5.19, takes precedence.
+	if err := os.WriteFile(filepath.Join(dir, "memory.peak"), []byte("300\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	got, err = source.Stats("app-baseline-tbnfsga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MemoryMaxUsageBytes != 300 {
+		t.Errorf("MemoryMaxUsageBytes = %d, want 300", got.MemoryMaxUsageBytes)
+	}
+
+	if _, err := source.Stats("missing"); err == nil {
+		t.Errorf("Stats(missing) succeeded, want error")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// stop is closed when loadgen is asked to stop early, e.g. by the runner when
+// a run exceeds its deadline. Requests in flight end and the results collected
+// so far are saved.
+var stop = make(chan struct{})
+
+// errStopped is the error when loadgen is asked to stop before the test
+// starts, such that there are no results to save.
+var errStopped = errors.New("stopped early")
+
+// stopOnSignal closes stop on SIGTERM or SIGINT. A second signal exits
+// immediately, without saving results.
+func stopOnSignal() {
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-ch
+		log.Printf("Received %v, stopping early", sig)
+		close(stop)
+		<-ch
+		log.Print("Received second signal, exiting")
+		os.Exit(1)
+	}()
+}
+
+// stopped reports whether loadgen was asked to stop early.
+func stopped() bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
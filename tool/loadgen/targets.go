@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// Target is an endpoint of the target web app. Endpoints are requested in
+// proportion to their weights.
+type Target struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Weight uint   `json:"weight"`
+}
+
+// String returns the name of the endpoint, like "GET /fortunes".
+func (t Target) String() string {
+	return t.Method + " " + t.Path
+}
+
+// readTargets reads a JSON list of targets from the file at path.
+func readTargets(path string) []Target {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	var targets []Target
+	if err := json.Unmarshal(b, &targets); err != nil {
+		panic(fmt.Errorf("invalid targets file %q: %w", path, err))
+	}
+	if len(targets) == 0 {
+		panic(fmt.Errorf("invalid targets file %q: no targets", path))
+	}
+	for i, t := range targets {
+		if t.Method == "" {
+			targets[i].Method = http.MethodGet
+		}
+		if t.Weight == 0 {
+			panic(fmt.Errorf("invalid targets file %q: target %q has zero weight", path, t.Path))
+		}
+	}
+	return targets
+}
+
+// staticTargeter returns a targeter that always makes a GET request to url.
+func staticTargeter(url string) vegeta.Targeter {
+	return vegeta.NewStaticTargeter(vegeta.Target{
+		Method: http.MethodGet,
+		URL:    url,
+	})
+}
+
+// weightedTargeter returns a targeter that distributes requests among targets
+// in proportion to their weights. Target paths are resolved relative to base.
+//
+// Targets are picked with smooth weighted round-robin, such that the request
+// mix is deterministic and evenly spread over time. The targeter is safe for
+// concurrent use.
+func weightedTargeter(base string, targets []Target) vegeta.Targeter {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		panic(err)
+	}
+	urls := make([]string, len(targets))
+	var total int
+	for i, t := range targets {
+		ref, err := url.Parse(t.Path)
+		if err != nil {
+			panic(err)
+		}
+		urls[i] = baseURL.ResolveReference(ref).String()
+		total += int(t.Weight)
+	}
+
+	var mu sync.Mutex
+	current := make([]int, len(targets))
+	return func(tgt *vegeta.Target) error {
+		if tgt == nil {
+			return vegeta.ErrNilTarget
+		}
+		mu.Lock()
+		best := 0
+		for i, t := range targets {
+			current[i] += int(t.Weight)
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		mu.Unlock()
+
+		tgt.Method = targets[best].Method
+		tgt.URL = urls[best]
+		return nil
+	}
+}
+
+// EndpointResult is the data collected for one endpoint of the target.
+type EndpointResult struct {
+	Endpoint string `json:"endpoint"`
+	Weight   uint   `json:"weight"`
+	*vegeta.Metrics
+	HDR string `json:"hdr"` // latency percentiles in HDR histogram plot format
+}
+
+// endpointMetrics accumulates the metrics of every endpoint of the targets, by
+// endpoint name.
+type endpointMetrics map[string]*vegeta.Metrics
+
+func newEndpointMetrics(targets []Target) endpointMetrics {
+	metrics := make(endpointMetrics)
+	for _, t := range targets {
+		metrics[t.String()] = &vegeta.Metrics{}
+	}
+	return metrics
+}
+
+// Add adds a result to the metrics of its endpoint.
+func (em endpointMetrics) Add(r *vegeta.Result) {
+	if m, ok := em[endpoint(r)]; ok {
+		m.Add(r)
+	}
+}
+
+// endpointResults breaks down results by endpoint.
+func endpointResults(targets []Target, metrics endpointMetrics) []EndpointResult {
+	var results []EndpointResult
+	for _, t := range targets {
+		m := metrics[t.String()]
+		m.Close()
+		var b bytes.Buffer
+		_ = vegeta.NewHDRHistogramPlotReporter(m).Report(&b)
+		results = append(results, EndpointResult{
+			Endpoint: t.String(),
+			Weight:   t.Weight,
+			Metrics:  m,
+			HDR:      b.String(),
+		})
+	}
+	return results
+}
+
+// endpoint returns the name of the endpoint a result belongs to, like
+// "GET /fortunes".
+func endpoint(r *vegeta.Result) string {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return r.Method + " " + r.URL
+	}
+	return r.Method + " " + u.RequestURI()
+}
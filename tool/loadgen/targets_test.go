@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestWeightedTargeter(t *testing.T) {
+	tr := weightedTargeter("http://app:8080/base/", []Target{
+		{Method: "GET", Path: "/fortunes", Weight: 3},
+		{Method: "POST", Path: "update?queries=2", Weight: 1},
+	})
+	var got []string
+	for i := 0; i < 8; i++ {
+		var tgt vegeta.Target
+		if err := tr(&tgt); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, tgt.Method+" "+tgt.URL)
+	}
+	// Smooth weighted round-robin spreads the lighter target evenly.
+	a, b := "GET http://app:8080/fortunes", "POST http://app:8080/base/update?queries=2"
+	want := []string{a, a, b, a, a, a, b, a}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("targets = %q, want %q", got, want)
+	}
+	if err := tr(nil); err != vegeta.ErrNilTarget {
+		t.Errorf("tr(nil) = %v, want %v", err, vegeta.ErrNilTarget)
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		want      []Target
+		wantPanic string
+	}{
+		{
+			name: "valid",
+			json: `[{"path": "/fortunes", "weight": 3}, {"method": "POST", "path": "/update", "weight": 1}]`,
+			want: []Target{{Method: "GET", Path: "/fortunes", Weight: 3}, {Method: "POST", Path: "/update", Weight: 1}},
+		},
+		{name: "zero weight", json: `[{"path": "/fortunes", "weight": 0}]`, wantPanic: "zero weight"},
+		{name: "missing weight", json: `[{"path": "/fortunes"}]`, wantPanic: "zero weight"},
+		{name: "negative weight", json: `[{"path": "/fortunes", "weight": -1}]`, wantPanic: "cannot unmarshal"},
+		{name: "bad JSON", json: `[{"path": "/fortunes",}]`, wantPanic: "invalid targets file"},
+		{name: "no targets", json: `[]`, wantPanic: "no targets"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "targets.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0666); err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				r := recover()
+				if tt.wantPanic == "" {
+					if r != nil {
+						t.Fatalf("readTargets() panicked: %v", r)
+					}
+					return
+				}
+				err, _ := r.(error)
+				if err == nil || !strings.Contains(err.Error(), tt.wantPanic) {
+					t.Errorf("readTargets() panicked with %v, want %q", r, tt.wantPanic)
+				}
+			}()
+			got := readTargets(path)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readTargets() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointResults(t *testing.T) {
+	targets := []Target{
+		{Method: "GET", Path: "/fortunes", Weight: 3},
+		{Method: "GET", Path: "/update?queries=2", Weight: 1},
+	}
+	metrics := newEndpointMetrics(targets)
+	for i, u := range []string{
+		"http://app:8080/fortunes",
+		"http://app:8080/fortunes",
+		"http://app:8080/update?queries=2",
+		"http://app:8080/unknown",
+	} {
+		metrics.Add(&vegeta.Result{
+			Method:    "GET",
+			URL:       u,
+			Code:      200,
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   time.Duration(i+1) * time.Millisecond,
+		})
+	}
+	got := endpointResults(targets, metrics)
+	if len(got) != 2 {
+		t.Fatalf("got %d endpoints, want 2", len(got))
+	}
+	for i, want := range []struct {
+		endpoint string
+		weight   uint
+		requests uint64
+	}{
+		{"GET /fortunes", 3, 2},
+		{"GET /update?queries=2", 1, 1},
+	} {
+		e := got[i]
+		if e.Endpoint != want.endpoint || e.Weight != want.weight || e.Requests != want.requests {
+			t.Errorf("endpoint %d = %s, weight %d, %d requests, want %s, weight %d, %d requests",
+				i, e.Endpoint, e.Weight, e.Requests, want.endpoint, want.weight, want.requests)
+		}
+		if e.HDR == "" {
+			t.Errorf("endpoint %d has no HDR histogram", i)
+		}
+	}
+	if got[1].Latencies.Max != 3*time.Millisecond {
+		t.Errorf("max latency of %s = %v, want 3ms", got[1].Endpoint, got[1].Latencies.Max)
+	}
+}